@@ -0,0 +1,80 @@
+package extractor
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Directory of an AMP whose content is installed into the web application classpath
+const ampConfigDir = "config/"
+
+// Maps the AMP config directory to the web application classpath when the AMP is applied
+const ampFileMapping = `# Generated by Alfresco Model Extractor
+include.default=false
+/config=/WEB-INF/classes
+`
+
+// AMP implementation of moduleArchive. Classpath entries are placed under
+// config/, except module.properties, which an AMP keeps at its root.
+type ampArchive struct {
+	root           moduleArchive
+	propertiesPath string
+}
+
+// Helper function to get the AMP entry name of a module entry
+func (a ampArchive) entryName(name string) string {
+	switch {
+	case name == a.propertiesPath:
+		return "module.properties"
+	case strings.HasPrefix(name, "META-INF/"):
+		return name
+	default:
+		return ampConfigDir + name
+	}
+}
+
+func (a ampArchive) createDir(name string) error {
+	return a.root.createDir(a.entryName(name))
+}
+
+func (a ampArchive) createFile(name string, content []byte, compress bool) error {
+	return a.root.createFile(a.entryName(name), content, compress)
+}
+
+func (a ampArchive) copyFile(name string, src io.Reader, size int64, compress bool) error {
+	return a.root.copyFile(a.entryName(name), src, size, compress)
+}
+
+// Function to write the module as an AMP: module.properties at the root, the
+// classpath resources under config/ and a file-mapping.properties
+func createModuleAmp(ampPath string, layout moduleLayout) error {
+	ampFile, err := os.Create(ampPath)
+	if err != nil {
+		return err
+	}
+	defer ampFile.Close()
+
+	zipWriter := zip.NewWriter(ampFile)
+	defer zipWriter.Close()
+
+	return writeModuleAmp(newZipArchive(zipWriter, layout), layout)
+}
+
+// Function to write the AMP structure of the module to the root of an archive
+func writeModuleAmp(root moduleArchive, layout moduleLayout) error {
+	if err := root.createDir(ampConfigDir); err != nil {
+		return err
+	}
+	layout.Amp = true
+	archive := ampArchive{
+		root:           root,
+		propertiesPath: fmt.Sprintf("alfresco/module/%s/module.properties", layout.Name),
+	}
+	if err := writeModule(archive, layout, true); err != nil {
+		return err
+	}
+	return root.createFile("file-mapping.properties", []byte(ampFileMapping), true)
+}
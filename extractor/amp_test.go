@@ -0,0 +1,31 @@
+package extractor
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestCreateModuleAmp(t *testing.T) {
+	layout := testLayout(t, writeTestModelFile(t))
+	ampPath := filepath.Join(t.TempDir(), "acme.amp")
+	if err := createModuleAmp(ampPath, layout); err != nil {
+		t.Fatal(err)
+	}
+	entries := testArchiveEntries(t, ampPath)
+	for _, want := range []string{
+		"module.properties",
+		"file-mapping.properties",
+		"META-INF/MANIFEST.MF",
+		"config/alfresco/module/acme/module-context.xml",
+		"config/alfresco/module/acme/model/model.xml",
+	} {
+		if !slices.Contains(entries, want) {
+			t.Errorf("AMP has no entry %s: %v", want, entries)
+		}
+	}
+	// The Module Management Tool reads module.properties from the root only
+	if slices.Contains(entries, "config/alfresco/module/acme/module.properties") {
+		t.Errorf("AMP has a module.properties under config/: %v", entries)
+	}
+}
@@ -0,0 +1,65 @@
+package extractor
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Digest algorithms accepted by -checksums, by sidecar file extension
+var checksumAlgorithms = map[string]func() hash.Hash{
+	"sha256": sha256.New,
+	"md5":    md5.New,
+}
+
+// Helper function to parse the comma-separated list of -checksums algorithms
+func parseChecksums(list string) ([]string, error) {
+	var algorithms []string
+	for _, algorithm := range strings.Split(list, ",") {
+		algorithm = strings.ToLower(strings.TrimSpace(algorithm))
+		if algorithm == "" {
+			continue
+		}
+		if _, ok := checksumAlgorithms[algorithm]; !ok {
+			return nil, fmt.Errorf("unknown algorithm %q: use sha256 or md5", algorithm)
+		}
+		algorithms = append(algorithms, algorithm)
+	}
+	return algorithms, nil
+}
+
+// Function to write a <file>.<algorithm> sidecar for each algorithm, in the
+// format of sha256sum and md5sum so that `sha256sum -c` can verify the file
+// from its directory
+func writeChecksums(filePath string, algorithms []string) error {
+	for _, algorithm := range algorithms {
+		digest, err := fileDigest(filePath, checksumAlgorithms[algorithm]())
+		if err != nil {
+			return err
+		}
+		line := fmt.Sprintf("%x  %s\n", digest, filepath.Base(filePath))
+		if err := os.WriteFile(filePath+"."+algorithm, []byte(line), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Helper function to stream a file through a hash
+func fileDigest(filePath string, digest hash.Hash) ([]byte, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(digest, file); err != nil {
+		return nil, err
+	}
+	return digest.Sum(nil), nil
+}
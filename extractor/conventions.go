@@ -0,0 +1,75 @@
+package extractor
+
+import (
+	"path"
+	"strings"
+)
+
+// Classpath conventions a model can be found under in the source archive
+const (
+	conventionModule    = "module"
+	conventionExtension = "extension"
+	conventionLoose     = "loose"
+)
+
+// Function to detect the classpath convention of an archive entry, returning
+// the convention name and the entry path relative to the convention root:
+//   - alfresco/module/<name>/model/... is relative to the model directory
+//   - alfresco/module/<name>/... is relative to the module directory
+//   - alfresco/extension/... is relative to the extension directory
+//   - anything else is a loose model, kept relative to the archive root
+func detectConvention(entryName string) (string, string) {
+	if rest, ok := strings.CutPrefix(entryName, "alfresco/module/"); ok {
+		if _, rest, ok := strings.Cut(rest, "/"); ok {
+			if relative, ok := strings.CutPrefix(rest, "model/"); ok {
+				return conventionModule, relative
+			}
+			return conventionModule, rest
+		}
+	}
+	if relative, ok := strings.CutPrefix(entryName, "alfresco/extension/"); ok {
+		return conventionExtension, relative
+	}
+	return conventionLoose, strings.TrimPrefix(entryName, "/")
+}
+
+// Function to compute the target path of a model relative to the module model
+// directory, either flattened to its base name, keeping its convention-relative
+// path or keeping its whole path in the source archive
+func modelTarget(entryName string, keepConventionPath, keepEntryPath bool) (string, string) {
+	convention, relative := detectConvention(entryName)
+	if keepEntryPath {
+		relative = strings.TrimPrefix(strings.ReplaceAll(entryName, "\\", "/"), "/")
+	} else if !keepConventionPath {
+		return convention, path.Base(entryName)
+	}
+	// Never let a relative path climb out of the model directory
+	cleaned := path.Clean(relative)
+	if cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return convention, path.Base(entryName)
+	}
+	return convention, cleaned
+}
+
+// Function to place every model in a subdirectory named after the prefix of its
+// primary namespace. Models without a parsed namespace, or whose prefix is not
+// a valid namespace prefix and could climb out of the model directory, stay
+// where they are.
+func groupByNamespace(logs logger, files []extractedFile) {
+	for i, file := range files {
+		if file.Model == nil {
+			logs.warnf("%s has no parsed namespace, it is not grouped", file.Entry)
+			continue
+		}
+		namespace, ok := primaryNamespace(file.Model)
+		if !ok || namespace.Prefix == "" {
+			logs.warnf("%s declares no namespace prefix, it is not grouped", file.Entry)
+			continue
+		}
+		if !namespacePrefixRegex.MatchString(namespace.Prefix) {
+			logs.warnf("%s declares the invalid namespace prefix %q, it is not grouped", file.Entry, namespace.Prefix)
+			continue
+		}
+		files[i].Target = path.Join(namespace.Prefix, file.Target)
+	}
+}
@@ -0,0 +1,31 @@
+package extractor
+
+import "testing"
+
+func TestGroupByNamespace(t *testing.T) {
+	tests := []struct {
+		prefix string
+		want   string
+	}{
+		{"acme", "acme/model.xml"},
+		{"acme.v2", "acme.v2/model.xml"},
+		{"", "model.xml"},
+		{"../../../../tmp/pwn", "model.xml"},
+		{"..", "model.xml"},
+		{"acme/../..", "model.xml"},
+	}
+	for _, tt := range tests {
+		files := []extractedFile{{
+			Entry:  "model.xml",
+			Target: "model.xml",
+			Model: &Model{
+				Name:       tt.prefix + ":model",
+				Namespaces: []Namespace{{URI: testNamespaceURI("acme"), Prefix: tt.prefix}},
+			},
+		}}
+		groupByNamespace(testLogger, files)
+		if files[0].Target != tt.want {
+			t.Errorf("groupByNamespace(prefix %q) target = %q, want %q", tt.prefix, files[0].Target, tt.want)
+		}
+	}
+}
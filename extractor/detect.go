@@ -0,0 +1,180 @@
+package extractor
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Glob patterns given with -match and -skip, applied to the base name of the
+// XML entries before their content is checked
+type entryPatterns struct {
+	match []string
+	skip  []string
+}
+
+// Function to validate the -match and -skip patterns
+func newEntryPatterns(match, skip []string) (entryPatterns, error) {
+	for _, pattern := range slices.Concat(match, skip) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return entryPatterns{}, fmt.Errorf("invalid pattern %q: %v", pattern, err)
+		}
+	}
+	return entryPatterns{match: match, skip: skip}, nil
+}
+
+// Helper function to check whether an entry matches a -match pattern, when any
+// is given, and no -skip pattern
+func (p entryPatterns) accepts(name string) bool {
+	base := path.Base(name)
+	matches := func(pattern string) bool {
+		matched, _ := path.Match(pattern, base)
+		return matched
+	}
+	if len(p.match) > 0 && !slices.ContainsFunc(p.match, matches) {
+		return false
+	}
+	return !slices.ContainsFunc(p.skip, matches)
+}
+
+// Outcome of isAlfrescoModel for an archive entry. A model is extracted to
+// path while it is read, so that it doesn't have to be read again, or kept in
+// content when there is no extraction directory.
+type modelDetection struct {
+	isModel bool
+	path    string
+	content []byte
+	hasBOM  bool // The entry starts with a UTF-8 BOM, removed from the copy with stripBOM
+	err     error
+}
+
+// Function to run isAlfrescoModel on the candidate entries of every archive
+// with a pool of GOMAXPROCS workers, skipping the XML entries accepts rejects.
+// Each worker extracts the models it finds to dir, so no more than the head of
+// an entry per worker is held in memory; with an empty dir, e.g. for -dry-run,
+// the models are kept in memory instead. The scan loop then reads the results
+// in entry order, so the models found keep a deterministic order. With
+// failFast, the first entry that can't be read stops the pool and is returned.
+func detectModels(logs logger, archives []inputArchive, accepts func(name string) bool, dir string, stripBOM, failFast bool) (map[*zip.File]modelDetection, error) {
+	var candidates []*zip.File
+	for _, archive := range archives {
+		for _, file := range archive.Reader.File {
+			if isDirEntry(file) {
+				logs.debugf("Not checking %s for a model, it is a directory entry", file.Name)
+				continue
+			}
+			if file.UncompressedSize64 == 0 || !strings.HasSuffix(strings.ToLower(file.Name), ".xml") {
+				continue
+			}
+			if archive.IsWar && !strings.HasPrefix(file.Name, archive.TrimPrefix) || !accepts(file.Name) {
+				continue
+			}
+			candidates = append(candidates, file)
+		}
+	}
+
+	results := make([]modelDetection, len(candidates))
+	pending := make(chan int)
+	// Closed by the first failure with failFast, so no further entry is handed out
+	abort := make(chan struct{})
+	var failure error
+	var failOnce sync.Once
+	var workers sync.WaitGroup
+	for range min(runtime.GOMAXPROCS(0), len(candidates)) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for i := range pending {
+				results[i] = detectCandidate(logs, candidates[i], i, dir, stripBOM)
+				if failFast && results[i].err != nil {
+					failOnce.Do(func() {
+						failure = fmt.Errorf("failed to read %s: %v", candidates[i].Name, results[i].err)
+						close(abort)
+					})
+				}
+			}
+		}()
+	}
+feed:
+	for i := range candidates {
+		select {
+		case pending <- i:
+		case <-abort:
+			break feed
+		}
+	}
+	close(pending)
+	workers.Wait()
+	if failure != nil {
+		return nil, failure
+	}
+
+	detections := make(map[*zip.File]modelDetection, len(candidates))
+	for i, file := range candidates {
+		detections[file] = results[i]
+	}
+	return detections, nil
+}
+
+// Helper function to run detectModel on the candidate of index i, extracting
+// it to dir, or in memory when dir is empty
+func detectCandidate(logs logger, file *zip.File, i int, dir string, stripBOM bool) modelDetection {
+	if dir == "" {
+		return detectModel(logs, file, "", stripBOM)
+	}
+	// Models may share base names, so the index keeps the copies apart
+	destPath, err := extractionPath(dir, fmt.Sprintf("%d-%s", i, path.Base(file.Name)))
+	if err != nil {
+		return modelDetection{err: err}
+	}
+	return detectModel(logs, file, destPath, stripBOM)
+}
+
+// Function to open an entry once, checking its root element and copying it to
+// destPath only when it turns out to be a model: the head read by the check is
+// written first, then the rest of the entry is streamed after it. An empty
+// destPath reads the model in memory.
+func detectModel(logs logger, file *zip.File, destPath string, stripBOM bool) modelDetection {
+	rc, err := file.Open()
+	if err != nil {
+		return modelDetection{err: err}
+	}
+	defer rc.Close()
+
+	var head bytes.Buffer
+	isModel, err := isAlfrescoModel(logs, file.Name, io.TeeReader(rc, &head))
+	if err != nil || !isModel {
+		return modelDetection{err: err}
+	}
+	content, hasBOM := bytes.CutPrefix(head.Bytes(), []byte(utf8BOM))
+	if !stripBOM {
+		content = head.Bytes()
+	}
+	if destPath == "" {
+		rest, err := io.ReadAll(rc)
+		if err != nil {
+			return modelDetection{err: err}
+		}
+		return modelDetection{isModel: true, content: append(content, rest...), hasBOM: hasBOM}
+	}
+
+	dest, err := os.Create(destPath)
+	if err != nil {
+		return modelDetection{err: err}
+	}
+	defer dest.Close()
+	if _, err := dest.Write(content); err != nil {
+		return modelDetection{err: err}
+	}
+	if _, err := io.Copy(dest, rc); err != nil {
+		return modelDetection{err: err}
+	}
+	return modelDetection{isModel: true, path: destPath, hasBOM: hasBOM}
+}
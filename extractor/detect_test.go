@@ -0,0 +1,208 @@
+package extractor
+
+import (
+	"archive/zip"
+	"compress/flate"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestDetectModelWithBOM(t *testing.T) {
+	input := writeTestArchive(t, "acme-1.0.jar", testEntry{"model.xml", utf8BOM + testModel})
+	file := openTestArchive(t, input).File[0]
+
+	tests := []struct {
+		name     string
+		stripBOM bool
+		want     string
+	}{
+		{"kept", false, utf8BOM + testModel},
+		{"stripped", true, testModel},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name+" in memory", func(t *testing.T) {
+			detection := detectModel(testLogger, file, "", tt.stripBOM)
+			if detection.err != nil || !detection.isModel {
+				t.Fatalf("detectModel = %+v, want a model", detection)
+			}
+			if !detection.hasBOM {
+				t.Error("hasBOM = false, want true")
+			}
+			if string(detection.content) != tt.want {
+				t.Errorf("content = %q, want %q", detection.content, tt.want)
+			}
+		})
+		t.Run(tt.name+" on disk", func(t *testing.T) {
+			destPath := filepath.Join(t.TempDir(), "model.xml")
+			detection := detectModel(testLogger, file, destPath, tt.stripBOM)
+			if detection.err != nil || !detection.isModel || !detection.hasBOM {
+				t.Fatalf("detectModel = %+v, want a model with a BOM", detection)
+			}
+			content, err := os.ReadFile(destPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(content) != tt.want {
+				t.Errorf("content = %q, want %q", content, tt.want)
+			}
+		})
+	}
+}
+
+// Helper function to build entries of which one in ten is a model, the others
+// being Spring contexts that are read up to their root element
+func manyTestEntries(count int) []testEntry {
+	entries := make([]testEntry, 0, count)
+	for i := range count {
+		if i%10 == 0 {
+			entries = append(entries, testEntry{fmt.Sprintf("alfresco/model/model-%d.xml", i), testModel})
+		} else {
+			entries = append(entries, testEntry{fmt.Sprintf("alfresco/context/context-%d.xml", i), `<beans xmlns="http://www.springframework.org/schema/beans"/>`})
+		}
+	}
+	return entries
+}
+
+// Filter of detectModels accepting every entry
+func acceptAll(string) bool { return true }
+
+func TestDetectModels(t *testing.T) {
+	entries := manyTestEntries(50)
+	input := writeTestArchive(t, "many-1.0.jar", entries...)
+	archives := []inputArchive{{Path: input, Reader: openTestArchive(t, input)}}
+	detections, err := detectModels(testLogger, archives, acceptAll, t.TempDir(), false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(detections) != len(entries) {
+		t.Fatalf("detectModels checked %d entries, want %d", len(detections), len(entries))
+	}
+	for file, detection := range detections {
+		if detection.err != nil {
+			t.Errorf("detection of %s failed: %v", file.Name, detection.err)
+		}
+		if want := strings.HasPrefix(file.Name, "alfresco/model/"); detection.isModel != want {
+			t.Errorf("%s detected as a model = %v, want %v", file.Name, detection.isModel, want)
+		}
+	}
+}
+
+func BenchmarkDetectModels(b *testing.B) {
+	input := writeTestArchive(b, "many-1.0.jar", manyTestEntries(5000)...)
+	archives := []inputArchive{{Path: input, Reader: openTestArchive(b, input)}}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		dir := b.TempDir()
+		b.StartTimer()
+		detections, err := detectModels(testLogger, archives, acceptAll, dir, false, false)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(detections) != 5000 {
+			b.Fatalf("detectModels checked %d entries, want 5000", len(detections))
+		}
+	}
+}
+
+func TestDetectModelsOpensEachEntryOnce(t *testing.T) {
+	entries := manyTestEntries(50)
+	input := writeTestArchive(t, "many-1.0.jar", entries...)
+	reader := openTestArchive(t, input)
+	// Every entry is deflated, so each Open of an entry creates one decompressor
+	var opens atomic.Int64
+	reader.RegisterDecompressor(zip.Deflate, func(r io.Reader) io.ReadCloser {
+		opens.Add(1)
+		return flate.NewReader(r)
+	})
+
+	archives := []inputArchive{{Path: input, Reader: reader}}
+	detections, err := detectModels(testLogger, archives, acceptAll, t.TempDir(), false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := opens.Load(); got != int64(len(entries)) {
+		t.Errorf("detectModels opened entries %d times, want %d", got, len(entries))
+	}
+	models := 0
+	for file, detection := range detections {
+		if detection.err != nil {
+			t.Errorf("detectModel(%s) failed: %v", file.Name, detection.err)
+		}
+		if !detection.isModel {
+			continue
+		}
+		models++
+		// The model was copied while it was checked, so it needn't be opened again
+		content, err := os.ReadFile(detection.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != testModel {
+			t.Errorf("copy of %s = %q, want %q", file.Name, content, testModel)
+		}
+	}
+	if models != len(entries)/10 {
+		t.Errorf("detectModels found %d models, want %d", models, len(entries)/10)
+	}
+}
+
+func TestDetectModelsFailFast(t *testing.T) {
+	archivePath := filepath.Join(t.TempDir(), "corrupt-1.0.jar")
+	file, err := os.Create(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zipWriter := zip.NewWriter(file)
+	// The first entry is stored with a wrong checksum, so reading it fails
+	corrupt := "alfresco/model/corrupt.xml"
+	writer, err := zipWriter.CreateRaw(&zip.FileHeader{
+		Name:               corrupt,
+		Method:             zip.Store,
+		CRC32:              1,
+		CompressedSize64:   uint64(len(testModel)),
+		UncompressedSize64: uint64(len(testModel)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writer.Write([]byte(testModel)); err != nil {
+		t.Fatal(err)
+	}
+	entries := manyTestEntries(500)
+	for _, entry := range entries {
+		writer, err := zipWriter.Create(entry.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := writer.Write([]byte(entry.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zipWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	reader := openTestArchive(t, archivePath)
+	var opens atomic.Int64
+	reader.RegisterDecompressor(zip.Deflate, func(r io.Reader) io.ReadCloser {
+		opens.Add(1)
+		return flate.NewReader(r)
+	})
+	archives := []inputArchive{{Path: archivePath, Reader: reader}}
+	_, err = detectModels(testLogger, archives, acceptAll, t.TempDir(), false, true)
+	if err == nil || !strings.Contains(err.Error(), corrupt) {
+		t.Fatalf("detectModels error = %v, want the failure of %s", err, corrupt)
+	}
+	// Only the entries handed out before the failure may have been read
+	if got := opens.Load(); got >= int64(len(entries))/2 {
+		t.Errorf("detectModels opened %d of %d entries after the failure", got, len(entries))
+	}
+}
@@ -0,0 +1,70 @@
+package extractor
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+)
+
+// Function to write a PlantUML class diagram showing the type hierarchy,
+// aspects and associations of the given models
+func writeDiagram(path string, models []*Model) error {
+	var buffer bytes.Buffer
+	buffer.WriteString("@startuml\n")
+	buffer.WriteString("' Generated by Alfresco Model Extractor\n")
+
+	// Declare classes grouped by model
+	for _, model := range models {
+		fmt.Fprintf(&buffer, "\npackage \"%s\" {\n", model.Name)
+		for _, class := range model.Types {
+			writeDiagramClass(&buffer, class, "type")
+		}
+		for _, class := range model.Aspects {
+			writeDiagramClass(&buffer, class, "aspect")
+		}
+		buffer.WriteString("}\n")
+	}
+
+	// Relations are declared after every class so that references across models resolve
+	buffer.WriteString("\n")
+	for _, model := range models {
+		for _, class := range model.Types {
+			writeDiagramRelations(&buffer, class)
+		}
+		for _, class := range model.Aspects {
+			writeDiagramRelations(&buffer, class)
+		}
+	}
+
+	buffer.WriteString("@enduml\n")
+	return os.WriteFile(path, buffer.Bytes(), 0644)
+}
+
+// Helper function to write a class with its properties
+func writeDiagramClass(buffer *bytes.Buffer, class Class, stereotype string) {
+	if len(class.Properties) == 0 {
+		fmt.Fprintf(buffer, "  class \"%s\" <<%s>>\n", class.Name, stereotype)
+		return
+	}
+	fmt.Fprintf(buffer, "  class \"%s\" <<%s>> {\n", class.Name, stereotype)
+	for _, property := range class.Properties {
+		fmt.Fprintf(buffer, "    %s : %s\n", property.Name, property.Type)
+	}
+	buffer.WriteString("  }\n")
+}
+
+// Helper function to write inheritance, mandatory aspects and associations of a class
+func writeDiagramRelations(buffer *bytes.Buffer, class Class) {
+	if class.Parent != "" {
+		fmt.Fprintf(buffer, "\"%s\" <|-- \"%s\"\n", class.Parent, class.Name)
+	}
+	for _, aspect := range class.MandatoryAspects {
+		fmt.Fprintf(buffer, "\"%s\" ..> \"%s\" : <<mandatory>>\n", class.Name, aspect)
+	}
+	for _, association := range class.Associations {
+		fmt.Fprintf(buffer, "\"%s\" --> \"%s\" : %s\n", class.Name, association.Target, association.Name)
+	}
+	for _, association := range class.ChildAssociations {
+		fmt.Fprintf(buffer, "\"%s\" *-- \"%s\" : %s\n", class.Name, association.Target, association.Name)
+	}
+}
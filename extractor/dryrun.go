@@ -0,0 +1,63 @@
+package extractor
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Entry that a -dry-run would write to the module archive
+type plannedEntry struct {
+	Name string
+	Size int64 // -1 for directories
+}
+
+// Implementation of moduleArchive recording the entries instead of writing them
+type plannedArchive struct {
+	entries *[]plannedEntry
+}
+
+func (a plannedArchive) createDir(name string) error {
+	if !strings.HasSuffix(name, "/") {
+		name += "/"
+	}
+	*a.entries = append(*a.entries, plannedEntry{Name: name, Size: -1})
+	return nil
+}
+
+func (a plannedArchive) createFile(name string, content []byte, compress bool) error {
+	*a.entries = append(*a.entries, plannedEntry{Name: name, Size: int64(len(content))})
+	return nil
+}
+
+func (a plannedArchive) copyFile(name string, src io.Reader, size int64, compress bool) error {
+	*a.entries = append(*a.entries, plannedEntry{Name: name, Size: size})
+	return nil
+}
+
+// Function to print the entries the module archive would contain, without
+// writing it or the copies of the generated files
+func printModulePlan(w io.Writer, layout moduleLayout, amp bool) error {
+	var entries []plannedEntry
+	archive := plannedArchive{&entries}
+	layout.GeneratedDir = ""
+
+	var err error
+	if amp {
+		err = writeModuleAmp(archive, layout)
+	} else {
+		err = writeModule(archive, layout, true)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if entry.Size < 0 {
+			fmt.Fprintf(w, "  %s\n", entry.Name)
+		} else {
+			fmt.Fprintf(w, "  %s (%d bytes)\n", entry.Name, entry.Size)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package extractor
+
+import (
+	"archive/zip"
+	"fmt"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/ianaindex"
+)
+
+// Flag set on ZIP entries whose name and comment are encoded in UTF-8
+const zipUTF8Flag = 0x800
+
+// Function to look up a character encoding by IANA name or alias, e.g. IBM437, cp437 or Shift_JIS
+func lookupEncoding(name string) (encoding.Encoding, error) {
+	enc, err := ianaindex.IANA.Encoding(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown encoding %q: %v", name, err)
+	}
+	if enc == nil {
+		return nil, fmt.Errorf("encoding %q is not supported", name)
+	}
+	return enc, nil
+}
+
+// Function to decode the names of the archive entries written by legacy tools
+// in a non-UTF-8 code page. Entries flagged as UTF-8 are kept as they are.
+func decodeEntryNames(files []*zip.File, enc encoding.Encoding) error {
+	decoder := enc.NewDecoder()
+	for _, file := range files {
+		if file.Flags&zipUTF8Flag != 0 {
+			continue
+		}
+		name, err := decoder.String(file.Name)
+		if err != nil {
+			return fmt.Errorf("failed to decode entry name %q: %v", file.Name, err)
+		}
+		file.Name = name
+	}
+	return nil
+}
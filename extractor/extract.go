@@ -0,0 +1,1122 @@
+package extractor
+
+import (
+	"archive/zip"
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"golang.org/x/text/encoding"
+)
+
+// Options of an extraction run, one field per command line flag. String fields
+// left empty take the default value of their flag.
+type Options struct {
+	Recursive            bool     // -recursive
+	Inputs               []string // -zip
+	Output               string   // -output
+	OutputDir            string   // -output-dir
+	RequireProperties    bool     // -require-properties
+	Checksums            string   // -checksums
+	Verify               bool     // -verify
+	BuiltBy              string   // -built-by
+	Format               string   // -format
+	Tier                 string   // -tier
+	TemplatesDir         string   // -templates
+	ContextTemplate      string   // -context-template
+	NoVersionIncrement   bool     // -no-version-increment
+	Version              string   // -version
+	Bump                 string   // -bump
+	KeepSnapshot         bool     // -keep-snapshot
+	BuildNumberFrom      string   // -build-number-from
+	BuildNumberStyle     string   // -build-number-style
+	Workflows            bool     // -workflows
+	Messages             bool     // -with-messages
+	Diagram              string   // -diagram
+	Layer                string   // -layer
+	ImagePath            string   // -image-path
+	Closure              string   // -closure
+	MergeModels          bool     // -merge-models
+	Merge                string   // -merge
+	Normalize            bool     // -normalize
+	Progress             bool     // -progress
+	Report               string   // -report
+	EmitGenerated        string   // -emit-generated
+	SourceDate           string   // -source-date
+	SourceIndex          bool     // -source-index
+	ForbiddenNamespaces  []string // -forbid-namespace
+	IncludePrefixes      []string // -include-prefix
+	MatchPatterns        []string // -match
+	SkipPatterns         []string // -skip
+	ExcludeFiles         []string // -exclude-file
+	ExcludePrefixes      []string // -exclude-prefix
+	Lock                 string   // -lock
+	UpdateLock           bool     // -update-lock
+	Lint                 bool     // -lint
+	ListNamespaces       bool     // -list-namespaces
+	AllowPrefixConflicts bool     // -allow-prefix-conflicts
+	ListFormat           string   // -list-format
+	SmokeTestURL         string   // -smoke-test
+	Timings              bool     // -timings
+	AllowEmpty           bool     // -allow-empty
+	StripBOM             bool     // -strip-bom
+	Validate             bool     // -validate
+	Strict               bool     // -strict
+	NestedDepth          int      // -nested-depth
+	FailFast             bool     // -fail-fast
+	IDSource             string   // -id-source
+	ID                   string   // -id
+	Title                string   // -title
+	Description          string   // -description
+	Properties           []string // -prop
+	ManifestEntries      []string // -manifest-entry
+	InstallState         string   // -install-state
+	Aliases              string   // -aliases
+	Name                 string   // -name
+	Rename               string   // -rename
+	NormalizeName        bool     // -normalize-module-name
+	EntryNameEncoding    string   // -entry-name-encoding
+	TrimPrefix           string   // -trim-prefix
+	ModelDir             string   // -model-dir
+	GroupByNamespace     bool     // -group-by-namespace
+	ConventionPaths      bool     // -convention-paths
+	Compression          string   // -compression
+	DryRun               bool     // -dry-run
+	OnCollision          string   // -on-collision
+	PreservePaths        bool     // -preserve-paths
+	LogLevel             string   // -log-level
+	Quiet                bool     // -quiet
+
+	ToolVersion string    // Version of the extractor recorded in the manifest, "dev" by default
+	Stdin       io.Reader // Archive read for the "-" input, os.Stdin by default
+	Stdout      io.Writer // Results, listings and dry-run plans, os.Stdout by default
+	Stderr      io.Writer // Logs, progress and timings, os.Stderr by default
+}
+
+// Default values of the options, shared with the command line flags
+var defaultOptions = Options{
+	Output:            "models.jar",
+	Format:            "jar",
+	Tier:              "repo",
+	Bump:              "patch",
+	BuildNumberStyle:  "segment",
+	ImagePath:         defaultImagePath,
+	ListFormat:        "text",
+	NestedDepth:       2,
+	IDSource:          "filename",
+	EntryNameEncoding: "UTF-8",
+	ModelDir:          "model",
+	OnCollision:       "rename",
+	LogLevel:          "info",
+	ToolVersion:       "dev",
+}
+
+// Function to get the default values of the options, e.g. for the defaults of
+// command line flags. The standard streams are left to Extract.
+func DefaultOptions() Options {
+	return defaultOptions
+}
+
+// Helper function to fill the empty string options with their default values
+func (o Options) withDefaults() Options {
+	if o.Output == "" && o.OutputDir == "" {
+		o.Output = defaultOptions.Output
+	}
+	if o.Format == "" {
+		o.Format = defaultOptions.Format
+	}
+	if o.Tier == "" {
+		o.Tier = defaultOptions.Tier
+	}
+	if o.Bump == "" {
+		o.Bump = defaultOptions.Bump
+	}
+	if o.BuildNumberStyle == "" {
+		o.BuildNumberStyle = defaultOptions.BuildNumberStyle
+	}
+	if o.ImagePath == "" {
+		o.ImagePath = defaultOptions.ImagePath
+	}
+	if o.ListFormat == "" {
+		o.ListFormat = defaultOptions.ListFormat
+	}
+	if o.IDSource == "" {
+		o.IDSource = defaultOptions.IDSource
+	}
+	if o.EntryNameEncoding == "" {
+		o.EntryNameEncoding = defaultOptions.EntryNameEncoding
+	}
+	if o.ModelDir == "" {
+		o.ModelDir = defaultOptions.ModelDir
+	}
+	if o.OnCollision == "" {
+		o.OnCollision = defaultOptions.OnCollision
+	}
+	if o.LogLevel == "" {
+		o.LogLevel = defaultOptions.LogLevel
+	}
+	if o.ToolVersion == "" {
+		o.ToolVersion = defaultOptions.ToolVersion
+	}
+	if o.Stdin == nil {
+		o.Stdin = os.Stdin
+	}
+	if o.Stdout == nil {
+		o.Stdout = os.Stdout
+	}
+	if o.Stderr == nil {
+		o.Stderr = os.Stderr
+	}
+	return o
+}
+
+// Outcome of an extraction run
+type Result struct {
+	Version    string   // Version of the module
+	ModelPaths []string // Path of each packaged model in the output
+	Skipped    int      // Archives and models skipped because they could not be processed
+}
+
+// Function to build the module described by opts: scan the input archives,
+// analyse the models and write the output. Errors that stop the build are
+// returned; problems that don't are logged and counted in Result.Skipped.
+// Each run logs at the level of its own options to opts.Stderr and prints
+// its results to opts.Stdout, so runs can be concurrent.
+func Extract(opts Options) (result Result, err error) {
+	opts = opts.withDefaults()
+
+	logs, err := newLogger(opts.LogLevel, opts.Quiet, opts.Stdout, opts.Stderr)
+	if err != nil {
+		return result, err
+	}
+	// Whatever the successful return, what couldn't be processed is reported last
+	defer func() {
+		if err == nil && result.Skipped > 0 {
+			logs.warnf("%d archives or models could not be processed and were skipped", result.Skipped)
+		}
+	}()
+
+	inputs := splitInputs(opts.Inputs)
+	if len(inputs) == 0 {
+		return result, errors.New("please provide a ZIP file path using -zip flag")
+	}
+	inputs, err = expandGlobs(inputs)
+	if err != nil {
+		return result, fmt.Errorf("failed to expand -zip: %v", err)
+	}
+
+	modelDir, err := cleanModelDir(opts.ModelDir)
+	if err != nil {
+		return result, fmt.Errorf("invalid -model-dir: %v", err)
+	}
+
+	trimPrefix := normalizeTrimPrefix(opts.TrimPrefix)
+
+	// Flate level of the JAR entries, 0 keeping the standard level
+	compressionLevel, storeEntries := 0, false
+	switch c := opts.Compression; {
+	case c == "":
+	case c == "store" || c == "0":
+		storeEntries = true
+	case len(c) == 1 && c[0] >= '1' && c[0] <= '9':
+		compressionLevel = int(c[0] - '0')
+	default:
+		return result, fmt.Errorf("invalid -compression %q: must be a level from 0 to 9 or store", c)
+	}
+
+	if opts.OnCollision != "rename" && opts.OnCollision != "fail" {
+		return result, fmt.Errorf("invalid -on-collision %q: must be rename or fail", opts.OnCollision)
+	}
+	if opts.ConventionPaths && opts.PreservePaths {
+		return result, errors.New("-convention-paths and -preserve-paths cannot be used together")
+	}
+	if opts.MergeModels && opts.Merge != "" {
+		return result, errors.New("-merge-models and -merge cannot be used together")
+	}
+	if opts.NestedDepth < 0 {
+		return result, fmt.Errorf("invalid -nested-depth %d: must not be negative", opts.NestedDepth)
+	}
+
+	// An explicit version may not be dotted
+	if opts.Version != "" && !dottedVersionRegex.MatchString(opts.Version) {
+		logs.warnf("-version %q doesn't look like a dotted version such as 1.2.3", opts.Version)
+	}
+
+	if _, ok := bumpSegments[opts.Bump]; !ok {
+		return result, fmt.Errorf("invalid -bump %q: use major, minor or patch", opts.Bump)
+	}
+
+	if opts.BuildNumberStyle != "segment" && opts.BuildNumberStyle != "metadata" {
+		return result, fmt.Errorf("invalid -build-number-style %q: use segment or metadata", opts.BuildNumberStyle)
+	}
+
+	switch opts.Format {
+	case "jar", "amp":
+	case "targz":
+		if opts.Verify {
+			return result, errors.New("-verify only supports the jar and amp formats")
+		}
+	default:
+		return result, fmt.Errorf("invalid -format %q: use jar, amp or targz", opts.Format)
+	}
+
+	switch opts.Tier {
+	case "repo":
+	case "share":
+		if opts.Workflows {
+			return result, errors.New("-workflows needs -tier repo, as workflow definitions are deployed by the repository")
+		}
+	default:
+		return result, fmt.Errorf("invalid -tier %q: use repo or share", opts.Tier)
+	}
+
+	if opts.ListFormat != "text" && opts.ListFormat != "json" {
+		return result, fmt.Errorf("invalid -list-format %q: use text or json", opts.ListFormat)
+	}
+
+	switch opts.IDSource {
+	case "filename", "model":
+	case "flag":
+		if opts.ID == "" {
+			return result, errors.New("-id-source flag requires -id")
+		}
+	default:
+		return result, fmt.Errorf("invalid -id-source %q: use filename, model or flag", opts.IDSource)
+	}
+
+	switch opts.InstallState {
+	case "", "INSTALLED", "DISABLED", "UNINSTALLED":
+	default:
+		return result, fmt.Errorf("invalid -install-state %q: use INSTALLED, DISABLED or UNINSTALLED", opts.InstallState)
+	}
+	moduleAliases := cleanAliases(opts.Aliases)
+
+	// Entries are stamped with a fixed date for reproducible builds, or the current time
+	modified, err := sourceDate(opts.SourceDate, os.Getenv("SOURCE_DATE_EPOCH"))
+	if err != nil {
+		return result, fmt.Errorf("invalid -source-date or SOURCE_DATE_EPOCH: %v", err)
+	}
+
+	var entryNameEncoding encoding.Encoding
+	if !strings.EqualFold(opts.EntryNameEncoding, "UTF-8") {
+		entryNameEncoding, err = lookupEncoding(opts.EntryNameEncoding)
+		if err != nil {
+			return result, fmt.Errorf("invalid -entry-name-encoding: %v", err)
+		}
+	}
+
+	// Parse the module rename rule
+	var renamePattern *regexp.Regexp
+	var renameReplacement string
+	if opts.Rename != "" {
+		renamePattern, renameReplacement, err = parseRename(opts.Rename)
+		if err != nil {
+			return result, fmt.Errorf("invalid -rename: %v", err)
+		}
+	}
+
+	stdinInputs := 0
+	for _, input := range inputs {
+		if input == stdinInput {
+			stdinInputs++
+		}
+	}
+	if stdinInputs > 1 {
+		return result, errors.New("-zip - can only be given once")
+	}
+	if stdinInputs > 0 && opts.Name == "" {
+		return result, errors.New("-zip - reads the archive from stdin and requires -name")
+	}
+	if opts.Name != "" && !isValidModuleName(opts.Name) {
+		return result, fmt.Errorf("invalid -name %q: only letters, digits, '-', '_' and '.' are allowed in a module id", opts.Name)
+	}
+
+	patterns, err := newEntryPatterns(opts.MatchPatterns, opts.SkipPatterns)
+	if err != nil {
+		return result, fmt.Errorf("invalid -match or -skip: %v", err)
+	}
+
+	checksums, err := parseChecksums(opts.Checksums)
+	if err != nil {
+		return result, fmt.Errorf("invalid -checksums: %v", err)
+	}
+
+	// Parse the additional module.properties entries
+	extraProperties, err := parseModuleProperties(opts.Properties)
+	if err != nil {
+		return result, fmt.Errorf("invalid -prop: %v", err)
+	}
+
+	// Parse the additional manifest headers
+	manifestEntries, err := parseManifestEntries(opts.ManifestEntries)
+	if err != nil {
+		return result, fmt.Errorf("invalid -manifest-entry: %v", err)
+	}
+
+	// Load templates, applying overrides from the templates directory
+	templates, err := loadTemplates(opts.TemplatesDir, opts.ContextTemplate, opts.Tier)
+	if err != nil {
+		return result, fmt.Errorf("failed to load templates: %v", err)
+	}
+
+	// Get module name from the first ZIP filename, removing version information.
+	// An archive read from stdin has no file name, so its name comes from -name.
+	moduleName, moduleNameSource := cleanModuleName(inputs[0]), "the file name"
+	if inputs[0] == stdinInput {
+		moduleName, moduleNameSource = opts.Name, "-name"
+	}
+
+	// Open the ZIP files, walking directories for archives with -recursive
+	var timings phaseTimings
+	// Printed on every return, so that early ones like -dry-run report their phases too
+	if opts.Timings {
+		defer timings.print(opts.Stderr)
+	}
+	timings.begin()
+	archivePaths, err := expandInputs(inputs, opts.Recursive, opts.Output)
+	if err != nil {
+		return result, fmt.Errorf("failed to find input archives: %v", err)
+	}
+	archives := make([]inputArchive, 0, len(archivePaths))
+	for _, archivePath := range archivePaths {
+		var archive inputArchive
+		if archivePath == stdinInput {
+			archive, err = openStdinArchive(logs, opts.Stdin, opts.Name, trimPrefix, entryNameEncoding)
+		} else {
+			archive, err = openInputArchive(logs, archivePath, trimPrefix, entryNameEncoding)
+		}
+		if err != nil {
+			// A single broken archive found by walking a directory doesn't stop the others
+			if opts.Recursive && !opts.FailFast {
+				logs.warnf("Skipping %s: %v", archivePath, err)
+				result.Skipped++
+				continue
+			}
+			return result, fmt.Errorf("failed to open ZIP file: %v", err)
+		}
+		if archive.Closer != nil {
+			defer archive.Closer.Close()
+		}
+		archives = append(archives, archive)
+		result.Skipped += archive.Skipped
+
+		// Archives shipped inside the input are scanned as inputs of their own
+		nested, skipped := openNestedArchives(logs, archive, entryNameEncoding, opts.NestedDepth, 1)
+		archives = append(archives, nested...)
+		result.Skipped += skipped
+	}
+
+	// Share modules declare their id and version in an extension module, which
+	// is used instead of the module.properties of the repository when found
+	if opts.Tier == "share" {
+		for i, archive := range archives {
+			module, found, err := readExtensionModule(logs, archive.Reader, archive.TrimPrefix)
+			if err != nil {
+				logs.warnf("Could not read the extension module of %s: %v", archive.Path, err)
+				result.Skipped++
+				continue
+			}
+			if found {
+				archives[i].Module = module
+			}
+		}
+	}
+
+	// Alfresco keys modules by id, so the module.id declared by the first archive
+	// is kept when repackaging it. Without one, its module directory is used when
+	// it doesn't match the file name. The archive is untrusted, so an id that
+	// isn't a valid module id, e.g. ../../escaped, is ignored.
+	if len(archives) > 0 && inputs[0] != stdinInput {
+		module := archives[0].Module
+		found := cmp.Or(module.ID, module.Name)
+		if found != "" && !isValidModuleName(found) {
+			logs.warnf("Ignoring invalid module id %q of %s, using %s derived from the file name", found, archives[0].Path, moduleName)
+			found = ""
+		}
+		if found != "" {
+			if found != moduleName {
+				logs.infof("Using module name %s from %s instead of %s derived from the file name", found, archives[0].Path, moduleName)
+			}
+			moduleName, moduleNameSource = found, fmt.Sprintf("the %s of %s", cmp.Or(module.Source, "module.properties"), archives[0].Path)
+		}
+	}
+
+	// An explicit -version is used verbatim, otherwise the version of the inputs is incremented
+	newVersion := opts.Version
+	if newVersion == "" {
+		currentVersion, err := inputsVersion(archives)
+		if err != nil {
+			return result, fmt.Errorf("failed to read module version: %v", err)
+		}
+		if currentVersion == "" {
+			if opts.RequireProperties {
+				return result, errors.New("no input provides a module.properties with a module.version")
+			}
+			logs.warnf("No input provides a module.properties with a module.version, assuming version %s", DefaultModuleVersion)
+			currentVersion = DefaultModuleVersion
+		}
+
+		// Increment the version unless the current one must be preserved
+		newVersion = currentVersion
+		if !opts.NoVersionIncrement {
+			newVersion = incrementVersion(currentVersion, opts.Bump, opts.KeepSnapshot)
+		}
+
+		// Append the CI build number, if available
+		if opts.BuildNumberFrom != "" {
+			if buildNumber := os.Getenv(opts.BuildNumberFrom); buildNumber != "" {
+				newVersion = appendBuildNumber(newVersion, buildNumber, opts.BuildNumberStyle)
+			} else {
+				logs.warnf("Environment variable %s is not set, no build number appended", opts.BuildNumberFrom)
+			}
+		}
+	}
+
+	timings.end("open")
+
+	// Rename the module, once its original name has been used to read the source archives
+	if renamePattern != nil {
+		renamed := renamePattern.ReplaceAllString(moduleName, renameReplacement)
+		if renamed == "" {
+			return result, fmt.Errorf("renaming module %s with -rename results in an empty name", moduleName)
+		}
+		if renamed != moduleName {
+			logs.infof("Renamed module %s to %s", moduleName, renamed)
+			moduleName, moduleNameSource = renamed, moduleNameSource+", renamed with -rename"
+		}
+	}
+
+	// Enforce Alfresco module id naming rules
+	if opts.NormalizeName {
+		normalized := normalizeModuleName(moduleName)
+		if normalized == "" {
+			return result, fmt.Errorf("module name %s has no valid characters left after normalization", moduleName)
+		}
+		if normalized != moduleName {
+			logs.infof("Normalized module name %s to %s", moduleName, normalized)
+			moduleName, moduleNameSource = normalized, moduleNameSource+", normalized"
+		}
+	}
+
+	// An explicit -name replaces the name derived from the filename
+	if opts.Name != "" {
+		moduleName, moduleNameSource = opts.Name, "-name"
+	}
+
+	// Without -output, the output is named after the module and its version in -output-dir
+	derivedOutput := opts.Output == ""
+	if derivedOutput {
+		extension := opts.Format
+		if extension == "targz" {
+			extension = "tar.gz"
+		}
+		// The name and version may come from an input archive, so they must not
+		// lead the output out of -output-dir
+		for _, part := range []string{moduleName, newVersion} {
+			if strings.ContainsAny(part, `/\`) || strings.Contains(part, "..") {
+				return result, fmt.Errorf("cannot name the output after %q in -output-dir: it contains a path separator or ..", part)
+			}
+		}
+		opts.Output = filepath.Join(opts.OutputDir, fmt.Sprintf("%s-%s.%s", moduleName, newVersion, extension))
+	}
+
+	// Writing the outputs must never truncate one of the archives being read
+	outputs := []struct{ flag, path string }{
+		{"-output", opts.Output},
+		{"-layer", opts.Layer},
+		{"-report", opts.Report},
+		{"-diagram", opts.Diagram},
+	}
+	for _, output := range outputs {
+		if output.path == "" {
+			continue
+		}
+		if err := checkOutputNotInput(output.flag, output.path, archivePaths); err != nil {
+			return result, err
+		}
+	}
+
+	// Create temporary directory for XML files. -dry-run writes nothing, the
+	// files being kept in memory instead.
+	timings.begin()
+	var tempDir, workflowDir, messagesDir string
+	if !opts.DryRun {
+		tempDir, err = os.MkdirTemp("", "alfresco-models")
+		if err != nil {
+			return result, fmt.Errorf("failed to create temp directory: %v", err)
+		}
+		defer os.RemoveAll(tempDir)
+
+		// Workflow definitions are extracted to their own directory
+		workflowDir = filepath.Join(tempDir, "workflow")
+		if opts.Workflows {
+			if err := os.Mkdir(workflowDir, 0755); err != nil {
+				return result, fmt.Errorf("failed to create temp directory: %v", err)
+			}
+		}
+
+		// And so are message bundles
+		messagesDir = filepath.Join(tempDir, "messages")
+		if opts.Messages {
+			if err := os.Mkdir(messagesDir, 0755); err != nil {
+				return result, fmt.Errorf("failed to create temp directory: %v", err)
+			}
+		}
+	}
+
+	// Helper function to extract a workflow or message bundle to dir, or to read
+	// it in memory with -dry-run
+	extractTo := func(file *zip.File, dir, name string) (extractedFile, error) {
+		if opts.DryRun {
+			content, err := readZipEntry(file)
+			return extractedFile{Content: content}, err
+		}
+		destPath, err := extractionPath(dir, name)
+		if err != nil {
+			return extractedFile{}, err
+		}
+		return extractedFile{Path: destPath}, extractFile(file, destPath)
+	}
+
+	// Entries that could not be scanned, either aborting right away or collected for the final report
+	var scanErrors []error
+	reportScanError := func(err error) {
+		scanErrors = append(scanErrors, err)
+	}
+
+	// Process ZIP contents. A workflow or message bundle whose output path was already
+	// taken, by another archive or another folder of the same one, is skipped;
+	// duplicate models are found once they are parsed.
+	modelFiles := make([]extractedFile, 0)
+	workflowFiles := make([]extractedFile, 0)
+	workflowSources := make(map[string]string) // target -> archive!/entry
+	messageFiles := make([]extractedFile, 0)
+	messageSources := make(map[string]string) // target -> archive
+	modelCounts := make(map[string]int)       // archive -> models found
+	// Model detection reads every XML entry, so it runs concurrently ahead of the
+	// scan, extracting the models to the temp directory
+	excludedFiles := make(map[string]bool, len(opts.ExcludeFiles))
+	for _, name := range opts.ExcludeFiles {
+		excludedFiles[name] = true
+	}
+	detections, err := detectModels(logs, archives, func(name string) bool {
+		return !excludedFiles[name] && patterns.accepts(name)
+	}, tempDir, opts.StripBOM, opts.FailFast)
+	if err != nil {
+		return result, fmt.Errorf("failed to scan archive: %v", err)
+	}
+	excludedByFile, excludedByPattern, excludedByContent := 0, 0, 0
+
+	totalEntries, processedEntries := 0, 0
+	for _, archive := range archives {
+		totalEntries += len(archive.Reader.File)
+	}
+scan:
+	for _, archive := range archives {
+		readEntry := archiveEntryReader(archive.Reader)
+		for _, file := range archive.Reader.File {
+			if opts.FailFast && len(scanErrors) > 0 {
+				break scan
+			}
+			if opts.Progress {
+				logs.progressf("processed %d/%d, %d models found", processedEntries, totalEntries, len(modelFiles))
+			}
+			processedEntries++
+			// Directory entries are never models, even when named like one (e.g. foo.xml/)
+			if isDirEntry(file) {
+				logs.debugf("Skipping %s, it is a directory entry", file.Name)
+				continue
+			}
+			if archive.IsWar && !strings.HasPrefix(file.Name, archive.TrimPrefix) {
+				logs.debugf("Skipping %s, outside %s", file.Name, archive.TrimPrefix)
+				continue
+			}
+			name := trimEntryPrefix(file.Name, archive.TrimPrefix)
+			if opts.Workflows && isWorkflowDefinition(file) {
+				target := path.Base(name)
+				// Two definitions with the same base name would be written to the same
+				// entry, even when they come from different folders of one archive
+				if source, taken := workflowSources[target]; taken {
+					logs.warnf("Skipping %s from %s, %s already provides workflow %s", file.Name, archive.Path, source, target)
+					continue
+				}
+				extracted, err := extractTo(file, workflowDir, fmt.Sprintf("%d-%s", len(workflowFiles), target))
+				if err != nil {
+					reportScanError(fmt.Errorf("failed to extract %s: %v", file.Name, err))
+					continue
+				}
+				extracted.Entry, extracted.Target, extracted.Archive = file.Name, target, archive.Path
+				workflowSources[target] = modelSource(extracted)
+				workflowFiles = append(workflowFiles, extracted)
+				continue
+			}
+			if opts.Messages && isMessageBundle(name) {
+				target := path.Base(name)
+				if source, taken := messageSources[target]; taken {
+					logs.warnf("Skipping %s from %s, %s already provides message bundle %s", file.Name, archive.Path, source, target)
+					continue
+				}
+				extracted, err := extractTo(file, messagesDir, fmt.Sprintf("%d-%s", len(messageFiles), target))
+				if err != nil {
+					reportScanError(fmt.Errorf("failed to extract %s: %v", file.Name, err))
+					continue
+				}
+				extracted.Entry, extracted.Target, extracted.Archive = file.Name, target, archive.Path
+				messageSources[target] = archive.Path
+				messageFiles = append(messageFiles, extracted)
+				continue
+			}
+			if !strings.HasSuffix(strings.ToLower(file.Name), ".xml") {
+				logs.debugf("Skipping %s, not an XML file", file.Name)
+			} else {
+				if excludedFiles[file.Name] {
+					logs.debugf("Skipping %s, excluded by -exclude-file", file.Name)
+					excludedByFile++
+					continue
+				}
+				if !patterns.accepts(file.Name) {
+					logs.debugf("Skipping %s, excluded by -match or -skip", file.Name)
+					excludedByPattern++
+					continue
+				}
+				// An empty XML file is suspicious rather than just "not a model"
+				if file.UncompressedSize64 == 0 {
+					logs.warnf("Skipping empty XML file %s", file.Name)
+					continue
+				}
+				detection := detections[file]
+				isModel, err := detection.isModel, detection.err
+				if err != nil {
+					reportScanError(fmt.Errorf("failed to read %s: %v", file.Name, err))
+					continue
+				}
+				if !isModel {
+					excludedByContent++
+					continue
+				}
+				logs.debugf("Found model %s in %s", file.Name, archive.Path)
+				convention, target := modelTarget(name, opts.ConventionPaths, opts.PreservePaths)
+				// The model was extracted during detection, without its BOM with -strip-bom
+				destPath, hasBOM := detection.path, detection.hasBOM
+				delete(detections, file)
+				if hasBOM && opts.StripBOM {
+					logs.infof("Stripped UTF-8 BOM from %s", file.Name)
+				} else if hasBOM {
+					logs.warnf("%s starts with a UTF-8 BOM, use -strip-bom to remove it", file.Name)
+				}
+				modelCounts[archive.Path]++
+				modelFiles = append(modelFiles, extractedFile{
+					Entry:      file.Name,
+					Path:       destPath,
+					Content:    detection.content,
+					Convention: convention,
+					Target:     target,
+					Archive:    archive.Path,
+					ReadEntry:  readEntry,
+				})
+			}
+		}
+	}
+
+	if opts.Progress {
+		logs.progressf("processed %d/%d, %d models found\n", processedEntries, totalEntries, len(modelFiles))
+	}
+	if opts.FailFast && len(scanErrors) > 0 {
+		return result, fmt.Errorf("failed to scan archive: %v", scanErrors[0])
+	}
+	if len(opts.MatchPatterns) > 0 || len(opts.SkipPatterns) > 0 {
+		logs.infof("Excluded %d XML entries by -match and -skip, and %d by content detection", excludedByPattern, excludedByContent)
+	}
+	timings.end("scan")
+
+	// Report every entry that could not be scanned
+	result.Skipped += len(scanErrors)
+	if len(scanErrors) > 0 {
+		logs.warnf("%d archive entries could not be scanned:", len(scanErrors))
+		for _, err := range scanErrors {
+			logs.logf(levelWarn, "  %v", err)
+		}
+	}
+
+	if len(modelFiles) == 0 && !opts.AllowEmpty {
+		return result, errors.New("no Alfresco content model XML files found")
+	}
+
+	// Parse the extracted models, resolving XIncludes from the archive so that
+	// the analysis covers the definitions split across files
+	timings.begin()
+	rejected := make(map[int]bool)
+	invalidModels := 0
+	for i, file := range modelFiles {
+		content, err := readExtractedFile(file)
+		if err != nil {
+			logs.warnf("Could not parse %s: %v", file.Entry, err)
+			continue
+		}
+		if usesXInclude(content) {
+			resolved, err := resolveXIncludes(content, file.Entry, file.ReadEntry, 0)
+			if err != nil {
+				logs.warnf("Could not resolve XIncludes in %s, model analysis is partial: %v", file.Entry, err)
+			} else {
+				content = resolved
+			}
+		}
+		model, err := parseModelContent(content)
+		if errors.Is(err, errEntityDeclaration) {
+			logs.warnf("Excluding %s: %v", file.Entry, err)
+			rejected[i] = true
+			continue
+		}
+		if err == nil && (opts.Validate || opts.Strict) {
+			err = validateModel(model)
+		}
+		if err != nil && (opts.Validate || opts.Strict) {
+			logs.warnf("Excluding %s, it is not a valid Alfresco model: %v", file.Entry, err)
+			rejected[i] = true
+			invalidModels++
+			continue
+		}
+		if err != nil {
+			logs.warnf("Could not parse %s: %v", file.Entry, err)
+			continue
+		}
+		modelFiles[i].Model = model
+	}
+	if opts.Strict && invalidModels > 0 {
+		return result, fmt.Errorf("%d models failed validation", invalidModels)
+	}
+
+	// Drop models rejected as unsafe or invalid
+	result.Skipped += len(rejected)
+	if len(rejected) > 0 {
+		kept := modelFiles[:0]
+		for i, file := range modelFiles {
+			if !rejected[i] {
+				kept = append(kept, file)
+			}
+		}
+		modelFiles = kept
+		if len(modelFiles) == 0 && !opts.AllowEmpty {
+			return result, errors.New("no Alfresco content model XML files found")
+		}
+	}
+
+	// Select the models by the namespace prefixes they declare
+	var filtered int
+	modelFiles, filtered = filterByPrefix(logs, modelFiles, opts.IncludePrefixes, opts.ExcludePrefixes)
+	if filtered > 0 && len(modelFiles) == 0 && !opts.AllowEmpty {
+		return result, errors.New("no Alfresco content model XML files left after filtering by namespace prefix")
+	}
+
+	// Models declaring the same name are duplicates, distinct models sharing a file name are
+	// renamed or, with -on-collision fail, abort the build
+	modelFiles, err = dedupeModels(logs, modelFiles, opts.OnCollision == "rename")
+	if err != nil {
+		return result, fmt.Errorf("output path collision: %v", err)
+	}
+
+	if len(modelFiles) == 0 {
+		logs.warnf("No Alfresco content model XML files found, creating a module without models")
+	}
+
+	// Keep only the models the -closure root depends on
+	if opts.Closure != "" {
+		closure, err := importClosure(modelFiles, opts.Closure)
+		if err != nil {
+			return result, fmt.Errorf("invalid -closure: %v", err)
+		}
+		logs.infof("Packaging %d of %d models in the import closure of %s", len(closure), len(modelFiles), opts.Closure)
+		modelFiles = closure
+	}
+
+	// Merge models sharing the same namespace into a single file
+	if opts.MergeModels {
+		modelFiles, err = mergeModelFiles(modelFiles, tempDir)
+		if err != nil {
+			return result, fmt.Errorf("failed to merge models: %v", err)
+		}
+	}
+
+	// Combine every model into the single model named by -merge
+	if opts.Merge != "" {
+		modelFiles, err = mergeAllModels(modelFiles, opts.Merge, tempDir)
+		if err != nil {
+			return result, fmt.Errorf("failed to merge models into %s: %v", opts.Merge, err)
+		}
+	}
+
+	// Rewrite the models with a consistent indentation and XML declaration
+	if opts.Normalize {
+		normalizeModelFiles(logs, modelFiles)
+	}
+
+	// Group models in subdirectories named after their namespace prefix
+	if opts.GroupByNamespace {
+		groupByNamespace(logs, modelFiles)
+	}
+
+	// Enforce the namespace policy given with -forbid-namespace
+	if offenders := findForbiddenNamespaces(modelFiles, opts.ForbiddenNamespaces); len(offenders) > 0 {
+		for _, offender := range offenders {
+			logs.logf(levelError, "  %s", offender)
+		}
+		return result, fmt.Errorf("found %d forbidden namespace declarations", len(offenders))
+	}
+
+	// Models authored against different dictionary versions may not be compatible
+	checkDictionaryVersions(logs, modelFiles)
+
+	// Imports should reference the namespace versions provided by the bundle
+	checkImportVersions(logs, modelFiles)
+
+	// Models importing each other in a cycle cannot be bootstrapped in any order
+	orderedModels, err := modelLoadOrder(modelFiles)
+	if err != nil {
+		return result, fmt.Errorf("failed to order models for bootstrap: %v", err)
+	}
+
+	// Report lint findings
+	if opts.Lint {
+		issues := lintModels(modelFiles)
+		for _, issue := range issues {
+			logs.logf(levelWarn, "Lint: %v", issue)
+		}
+		if len(issues) > 0 {
+			logs.warnf("Lint found %d issues", len(issues))
+		}
+	}
+
+	// Only list the namespaces, without building the JAR
+	if opts.ListNamespaces {
+		usages := collectNamespaces(modelFiles)
+		if err := printNamespaces(logs, opts.Stdout, usages, opts.ListFormat); err != nil {
+			return result, fmt.Errorf("failed to list namespaces: %v", err)
+		}
+		// A prefix bound to different URIs prevents the repository from starting
+		if prefixes := conflictingPrefixes(usages); len(prefixes) > 0 {
+			return result, fmt.Errorf("found %d namespace prefixes declared with multiple URIs: %s", len(prefixes), strings.Join(prefixes, ", "))
+		}
+		timings.end("analysis")
+		return result, nil
+	}
+
+	// Alfresco refuses to start when a prefix is bound to different URIs
+	if conflicts := findPrefixConflicts(modelFiles); len(conflicts) > 0 {
+		level := levelError
+		if opts.AllowPrefixConflicts {
+			level = levelWarn
+		}
+		for _, conflict := range conflicts {
+			logs.logf(level, "  %s", conflict)
+		}
+		if !opts.AllowPrefixConflicts {
+			return result, fmt.Errorf("%d namespace prefixes are declared with different URIs, use -allow-prefix-conflicts to package them anyway", len(conflicts))
+		}
+		logs.warnf("%d namespace prefixes are declared with different URIs", len(conflicts))
+	}
+
+	// Generate PlantUML diagram from the parsed models
+	if opts.Diagram != "" && !opts.DryRun {
+		if err := writeDiagram(opts.Diagram, parsedModels(modelFiles)); err != nil {
+			return result, fmt.Errorf("failed to write diagram: %v", err)
+		}
+	}
+
+	// Fail on unexpected model changes recorded in the lock file
+	if opts.Lock != "" && !opts.DryRun {
+		if err := checkLockFile(logs, opts.Lock, modelFiles, opts.UpdateLock); err != nil {
+			return result, fmt.Errorf("lock check failed: %v", err)
+		}
+	}
+
+	timings.end("analysis")
+
+	// Create JAR file with module structure and new version
+	timings.begin()
+	// Select what populates module.id, which may differ from the module directory name
+	moduleID, moduleIDSource := moduleName, moduleNameSource
+	switch opts.IDSource {
+	case "model":
+		moduleID, moduleIDSource = modelModuleID(modelFiles), "the model names"
+		if moduleID == "" {
+			return result, errors.New("-id-source model requires at least one parsed model with a name")
+		}
+	case "flag":
+		moduleID, moduleIDSource = opts.ID, "-id"
+	}
+
+	// The manifest is attributed to the current user unless -built-by is given
+	builtBy := opts.BuiltBy
+	if builtBy == "" {
+		builtBy = os.Getenv("USER")
+	}
+
+	layout := moduleLayout{
+		Name:             moduleName,
+		ID:               moduleID,
+		Tier:             opts.Tier,
+		InstallState:     opts.InstallState,
+		Aliases:          moduleAliases,
+		Title:            opts.Title,
+		Description:      opts.Description,
+		Properties:       extraProperties,
+		ManifestEntries:  manifestEntries,
+		ModelDir:         modelDir,
+		Version:          newVersion,
+		BuiltBy:          builtBy,
+		Tool:             opts.ToolVersion,
+		Models:           modelFiles,
+		Workflows:        workflowFiles,
+		Messages:         messageFiles,
+		Templates:        templates,
+		SourceIndex:      opts.SourceIndex,
+		GeneratedDir:     opts.EmitGenerated,
+		Modified:         modified,
+		CompressionLevel: compressionLevel,
+		StoreAll:         storeEntries,
+	}
+	createModule, classpathRoot, archiveKind := createModuleJar, "", "JAR"
+	switch opts.Format {
+	case "amp":
+		createModule, classpathRoot, archiveKind = createModuleAmp, ampConfigDir, "AMP"
+	case "targz":
+		createModule, archiveKind = createModuleTarGz, "tar.gz"
+	}
+	moduleDir := moduleResourceDir(opts.Tier, moduleName)
+	result.Version = newVersion
+	for _, file := range orderedModels {
+		result.ModelPaths = append(result.ModelPaths, classpathRoot+modelEntryPath(moduleDir, modelDir, file))
+	}
+
+	// Report what would be written, leaving every output untouched
+	if opts.DryRun {
+		fmt.Fprintf(opts.Stdout, "Dry run: %s file %s would contain %d model files (version %s):\n",
+			archiveKind, opts.Output, len(modelFiles), newVersion)
+		if err := printModulePlan(opts.Stdout, layout, opts.Format == "amp"); err != nil {
+			return result, fmt.Errorf("failed to plan %s file: %v", archiveKind, err)
+		}
+		timings.end("plan")
+		return result, nil
+	}
+	if derivedOutput {
+		if err := os.MkdirAll(opts.OutputDir, 0755); err != nil {
+			return result, fmt.Errorf("failed to create output directory: %v", err)
+		}
+	}
+	if err := createModule(opts.Output, layout); err != nil {
+		return result, fmt.Errorf("failed to create %s file: %v", archiveKind, err)
+	}
+
+	// Make sure every path referenced by module-context.xml can be loaded from the JAR.
+	// A tarball holds the same tree as the JAR, but can't be opened as a ZIP.
+	if opts.Format != "targz" {
+		if err := verifyContextPaths(opts.Output, classpathRoot, moduleDir+"/module-context.xml"); err != nil {
+			return result, fmt.Errorf("invalid %s file %s: %v", archiveKind, opts.Output, err)
+		}
+	}
+
+	// Check that the archive reads back with every entry the module needs
+	if opts.Verify {
+		propertiesPath := fmt.Sprintf("alfresco/module/%s/module.properties", moduleName)
+		switch {
+		case opts.Format == "amp":
+			propertiesPath = "module.properties"
+		case opts.Tier == "share":
+			propertiesPath = shareExtensionPath(moduleName)
+		}
+		expected := append([]string{
+			"META-INF/MANIFEST.MF",
+			propertiesPath,
+			classpathRoot + moduleDir + "/module-context.xml",
+		}, result.ModelPaths...)
+		if opts.Tier == "share" {
+			expected = append(expected, classpathRoot+shareImportPath(moduleName))
+		}
+		if opts.Tier == "share" && opts.Format == "amp" {
+			expected = append(expected, classpathRoot+shareExtensionPath(moduleName))
+		}
+		if err := verifyArchive(opts.Output, expected); err != nil {
+			return result, fmt.Errorf("%s file %s failed verification: %v", archiveKind, opts.Output, err)
+		}
+	}
+
+	// Write the digests of the closed archive next to it
+	if err := writeChecksums(opts.Output, checksums); err != nil {
+		return result, fmt.Errorf("failed to write checksums: %v", err)
+	}
+	timings.end("write JAR")
+
+	// Check that a real repository actually loads the packaged models
+	if opts.SmokeTestURL != "" {
+		if err := runSmokeTest(logs, opts.SmokeTestURL, orderedModels); err != nil {
+			return result, fmt.Errorf("smoke test failed: %v", err)
+		}
+	}
+
+	// Describe the packaged models for CI pipelines
+	if opts.Report != "" {
+		if err := writeReport(opts.Report, classpathRoot, moduleDir, modelDir, modelFiles); err != nil {
+			return result, fmt.Errorf("failed to write report: %v", err)
+		}
+	}
+
+	if opts.Workflows {
+		logs.resultf("Successfully created %s file %s with %d model files and %d workflow definitions (version %s)\n",
+			archiveKind, opts.Output, len(modelFiles), len(workflowFiles), newVersion)
+	} else {
+		logs.resultf("Successfully created %s file %s with %d model files (version %s)\n",
+			archiveKind, opts.Output, len(modelFiles), newVersion)
+	}
+
+	logs.resultf("Module id %s, taken from %s\n", moduleID, moduleIDSource)
+	if len(opts.ExcludeFiles) > 0 {
+		logs.resultf("Excluded %d files with -exclude-file\n", excludedByFile)
+	}
+
+	if opts.Messages {
+		logs.resultf("Registered %d message bundle files\n", len(messageFiles))
+	}
+	if len(opts.IncludePrefixes) > 0 || len(opts.ExcludePrefixes) > 0 {
+		logs.resultf("Filtered out %d models by namespace prefix\n", filtered)
+	}
+
+	// Report where each model came from
+	for _, file := range modelFiles {
+		source := file.Entry
+		if len(archives) > 1 {
+			source = fmt.Sprintf("%s!/%s", file.Archive, file.Entry)
+		}
+		logs.resultf("  %s [%s] (%s, dictionary %s) -> %s\n", source, modelName(file), file.Convention,
+			fileDictionaryVersion(file), modelEntryPath(moduleDir, modelDir, file))
+	}
+
+	// Summarize the archives found walking the input directories
+	if opts.Recursive {
+		logs.resultf("Scanned %d archives:\n", len(archives))
+		for _, archive := range archives {
+			if count := modelCounts[archive.Path]; count > 0 {
+				logs.resultf("  %s: %d models found\n", archive.Path, count)
+			} else {
+				logs.resultf("  %s: no models, skipped\n", archive.Path)
+			}
+		}
+	}
+
+	// Create container image layer with the same module structure
+	if opts.Layer != "" {
+		timings.begin()
+		if err := createModuleLayer(opts.Layer, opts.ImagePath, layout); err != nil {
+			return result, fmt.Errorf("failed to create image layer: %v", err)
+		}
+		timings.end("write layer")
+		logs.resultf("Successfully created image layer %s under %s\n", opts.Layer, opts.ImagePath)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,736 @@
+package extractor
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// Entry of an archive written by writeTestArchive; a name ending with / is a directory
+type testEntry struct {
+	name    string
+	content string
+}
+
+// Logger of the tests, printing errors only
+var testLogger, _ = newLogger("error", false, io.Discard, os.Stderr)
+
+// Smallest content model detected and parsed by the extractor
+const testModel = `<?xml version="1.0" encoding="UTF-8"?>
+<model name="acme:contentModel" xmlns="http://www.alfresco.org/model/dictionary/1.0">
+  <namespaces>
+    <namespace uri="http://www.acme.org/model/content/1.0" prefix="acme"/>
+  </namespaces>
+</model>
+`
+
+// Helper function to build a model named prefix:model declaring the namespace
+// prefix, and importing the namespaces of the imports prefixes
+func testModelWithPrefix(prefix string, imports ...string) string {
+	var model strings.Builder
+	model.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<model name="` + prefix + `:model" xmlns="http://www.alfresco.org/model/dictionary/1.0">
+`)
+	if len(imports) > 0 {
+		model.WriteString("  <imports>\n")
+		for _, imported := range imports {
+			model.WriteString(`    <import uri="` + testNamespaceURI(imported) + `" prefix="` + imported + `"/>` + "\n")
+		}
+		model.WriteString("  </imports>\n")
+	}
+	model.WriteString(`  <namespaces>
+    <namespace uri="` + testNamespaceURI(prefix) + `" prefix="` + prefix + `"/>
+  </namespaces>
+</model>
+`)
+	return model.String()
+}
+
+// Helper function to build the namespace URI of a model of testModelWithPrefix
+func testNamespaceURI(prefix string) string {
+	return "http://www.acme.org/model/" + prefix + "/1.0"
+}
+
+// Entry the model of module acme is packaged as
+const testModelPath = "alfresco/module/acme/model/model.xml"
+
+// Helper function to write a ZIP archive named name holding entries in order,
+// returning its path
+func writeTestArchive(t testing.TB, name string, entries ...testEntry) string {
+	t.Helper()
+	archivePath := filepath.Join(t.TempDir(), name)
+	file, err := os.Create(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	zipWriter := zip.NewWriter(file)
+	for _, entry := range entries {
+		writer, err := zipWriter.Create(entry.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := writer.Write([]byte(entry.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zipWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return archivePath
+}
+
+// Helper function to open a ZIP archive, closed when the test ends
+func openTestArchive(t testing.TB, archivePath string) *zip.Reader {
+	t.Helper()
+	zipReader, err := zip.OpenReader(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { zipReader.Close() })
+	return &zipReader.Reader
+}
+
+// Helper function to list the entry names of a ZIP archive
+func testArchiveEntries(t testing.TB, archivePath string) []string {
+	t.Helper()
+	var names []string
+	for _, file := range openTestArchive(t, archivePath).File {
+		names = append(names, file.Name)
+	}
+	return names
+}
+
+// Helper function to read the content of the entry name of a ZIP archive
+func readTestEntry(t testing.TB, archivePath, name string) string {
+	t.Helper()
+	for _, file := range openTestArchive(t, archivePath).File {
+		if file.Name != name {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rc.Close()
+		content, err := io.ReadAll(rc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(content)
+	}
+	t.Fatalf("%s has no entry %s", archivePath, name)
+	return ""
+}
+
+// Helper function to run Extract with opts, writing to a temp module unless
+// opts.Output is set, and returning what it printed to stdout and stderr
+func runExtract(t testing.TB, opts Options) (string, Result, error) {
+	t.Helper()
+	if opts.Output == "" {
+		opts.Output = filepath.Join(t.TempDir(), "models.jar")
+	}
+	var output bytes.Buffer
+	opts.Stdout, opts.Stderr = &output, &output
+	result, err := Extract(opts)
+	return output.String(), result, err
+}
+
+// Helper function to run Extract on input with opts, failing the test unless
+// it succeeds, and returning the path of the module it wrote
+func extractTest(t testing.TB, input string, opts Options) string {
+	t.Helper()
+	opts.Inputs = append([]string{input}, opts.Inputs...)
+	opts.Output = filepath.Join(t.TempDir(), "models.jar")
+	if out, _, err := runExtract(t, opts); err != nil {
+		t.Fatalf("Extract failed: %v\n%s", err, out)
+	}
+	return opts.Output
+}
+
+func TestCleanModuleName(t *testing.T) {
+	tests := []struct {
+		filename string
+		want     string
+	}{
+		// Versions
+		{"acme-repo-1.2.3.jar", "acme-repo"},
+		{"acme-1.0.amp", "acme"},
+		{"acme_v2.0.1.jar", "acme"},
+		{"acme-1.0-SNAPSHOT.jar", "acme"},
+		// Maven build qualifiers after the version
+		{"acme-platform-1.0.0-jar-with-dependencies.jar", "acme-platform"},
+		{"acme-1.0.0-classifier.jar", "acme"},
+		{"acme-1.0.0-sources.jar", "acme"},
+		{"acme-1.0.0-javadoc.jar", "acme"},
+		{"acme-1.0-SNAPSHOT-sources.jar", "acme"},
+		// Names without a version are untouched
+		{"acme.jar", "acme"},
+		{"acme-repo.jar", "acme-repo"},
+		{"acme-sources.zip", "acme-sources"},
+		{"my-javadoc.amp", "my-javadoc"},
+		{"acme-jar-with-dependencies.jar", "acme-jar-with-dependencies"},
+		// Trailing digits that are part of the name, not a version
+		{"log4j2.jar", "log4j2"},
+		{"oauth2.jar", "oauth2"},
+		{"base64.jar", "base64"},
+		{"acme-2.jar", "acme-2"},
+		{"acme_v2.jar", "acme_v2"},
+		{"log4j2-1.0.jar", "log4j2"},
+	}
+	for _, tt := range tests {
+		if got := cleanModuleName(tt.filename); got != tt.want {
+			t.Errorf("cleanModuleName(%q) = %q, want %q", tt.filename, got, tt.want)
+		}
+	}
+}
+
+func TestSkipDirectoryAndEmptyXMLEntries(t *testing.T) {
+	input := writeTestArchive(t, "acme-1.0.jar",
+		testEntry{"foo.xml/", ""},
+		testEntry{"bar.xml", ""},
+		testEntry{"model.xml", testModel},
+	)
+	entries := testArchiveEntries(t, extractTest(t, input, Options{}))
+	if !slices.Contains(entries, testModelPath) {
+		t.Errorf("entries = %v, want %s", entries, testModelPath)
+	}
+	for _, entry := range entries {
+		if strings.HasSuffix(entry, "/foo.xml") || strings.HasSuffix(entry, "/bar.xml") {
+			t.Errorf("entry %s is packaged, want only model.xml", entry)
+		}
+	}
+}
+
+func TestStripBOM(t *testing.T) {
+	input := writeTestArchive(t, "acme-1.0.jar", testEntry{"model.xml", utf8BOM + testModel})
+	for _, stripBOM := range []bool{false, true} {
+		content := readTestEntry(t, extractTest(t, input, Options{StripBOM: stripBOM}), testModelPath)
+		if hasBOM := strings.HasPrefix(content, utf8BOM); hasBOM == stripBOM {
+			t.Errorf("-strip-bom = %v: packaged model starts with a BOM = %v", stripBOM, hasBOM)
+		}
+	}
+}
+
+func TestIncrementVersion(t *testing.T) {
+	tests := []struct {
+		version      string
+		bump         string
+		keepSnapshot bool
+		want         string
+	}{
+		{"1.0.0", "patch", false, "1.0.1"},
+		{"1.0", "patch", false, "1.0.1"},
+		{"1.2.3", "minor", false, "1.3.0"},
+		{"1.2.3", "major", false, "2.0.0"},
+		// -SNAPSHOT is dropped unless it is kept
+		{"1.0.0-SNAPSHOT", "patch", false, "1.0.1"},
+		{"1.0.0-SNAPSHOT", "patch", true, "1.0.1-SNAPSHOT"},
+		// Other pre-releases and build metadata are kept
+		{"2.1.0-RC1", "patch", false, "2.1.1-RC1"},
+		{"3.0.0+sha.abc", "patch", false, "3.0.1+sha.abc"},
+		{"3.0.0-RC1+sha.abc", "minor", false, "3.1.0-RC1+sha.abc"},
+	}
+	for _, tt := range tests {
+		if got := incrementVersion(tt.version, tt.bump, tt.keepSnapshot); got != tt.want {
+			t.Errorf("incrementVersion(%q, %q, %v) = %q, want %q", tt.version, tt.bump, tt.keepSnapshot, got, tt.want)
+		}
+	}
+}
+
+// Helper function to build the layout of module acme packaging models with the
+// built-in templates
+func testLayout(t testing.TB, models ...extractedFile) moduleLayout {
+	t.Helper()
+	templates, err := loadTemplates("", "", "repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return moduleLayout{
+		Name:      "acme",
+		ID:        "acme",
+		Tier:      "repo",
+		ModelDir:  "model",
+		Version:   "1.0.0",
+		Models:    models,
+		Templates: templates,
+	}
+}
+
+// Helper function to write testModel to a temp file, packaged as model.xml
+func writeTestModelFile(t testing.TB) extractedFile {
+	t.Helper()
+	modelPath := filepath.Join(t.TempDir(), "model.xml")
+	if err := os.WriteFile(modelPath, []byte(testModel), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return extractedFile{Entry: "model.xml", Path: modelPath, Target: "model.xml"}
+}
+
+// Helper function to write a model of about size bytes to a temp file
+func writeLargeModel(t testing.TB, size int) extractedFile {
+	t.Helper()
+	var content strings.Builder
+	content.WriteString(strings.TrimSuffix(testModel, "</model>\n"))
+	line := "  <!-- Padding of a large content model, as generated by modelling tools -->\n"
+	for content.Len() < size {
+		content.WriteString(line)
+	}
+	content.WriteString("</model>\n")
+	modelPath := filepath.Join(t.TempDir(), "large-model.xml")
+	if err := os.WriteFile(modelPath, []byte(content.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return extractedFile{Entry: "large-model.xml", Path: modelPath, Target: "large-model.xml"}
+}
+
+func TestCreateModuleJarStreamsModels(t *testing.T) {
+	const size = 32 << 20
+	layout := testLayout(t, writeLargeModel(t, size))
+	jarPath := filepath.Join(t.TempDir(), "acme.jar")
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	if err := createModuleJar(jarPath, layout); err != nil {
+		t.Fatal(err)
+	}
+	runtime.ReadMemStats(&after)
+	// Reading the model in memory would allocate at least its size
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > size/4 {
+		t.Errorf("createModuleJar allocated %d bytes for a %d bytes model, want it streamed", allocated, size)
+	}
+}
+
+func BenchmarkCreateModuleJarLargeModel(b *testing.B) {
+	const size = 16 << 20
+	layout := testLayout(b, writeLargeModel(b, size))
+	jarPath := filepath.Join(b.TempDir(), "acme.jar")
+	b.ReportAllocs()
+	b.SetBytes(size)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := createModuleJar(jarPath, layout); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestIsAlfrescoModel(t *testing.T) {
+	// A license header larger than any fixed-size peek at the start of the entry
+	longComment := "<!--\n" + strings.Repeat("Licensed under the Apache License, Version 2.0.\n", 128) + "-->\n"
+	body := strings.TrimPrefix(testModel, `<?xml version="1.0" encoding="UTF-8"?>`+"\n")
+	tests := []struct {
+		name    string
+		content string
+		want    bool
+	}{
+		{"model", testModel, true},
+		{"6 KB comment before the root element", `<?xml version="1.0" encoding="UTF-8"?>` + "\n" + longComment + body, true},
+		{"BOM", utf8BOM + testModel, true},
+		{"other root element", `<beans xmlns="http://www.springframework.org/schema/beans"/>`, false},
+		{"model without name", `<model xmlns="http://www.alfresco.org/model/dictionary/1.0"/>`, false},
+		{"no root element", `<?xml version="1.0"?>`, false},
+		{"not XML", "model = acme", false},
+	}
+	if len(longComment) < 6<<10 {
+		t.Fatalf("comment is %d bytes, want at least 6 KB", len(longComment))
+	}
+	for _, tt := range tests {
+		got, err := isAlfrescoModel(testLogger, tt.name, strings.NewReader(tt.content))
+		if err != nil {
+			t.Errorf("isAlfrescoModel(%s) failed: %v", tt.name, err)
+		} else if got != tt.want {
+			t.Errorf("isAlfrescoModel(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestExtractionPath(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"model.xml", "0-model.xml", "sub/model.xml"} {
+		destPath, err := extractionPath(dir, name)
+		if err != nil {
+			t.Errorf("extractionPath(%q) failed: %v", name, err)
+		} else if destPath != filepath.Join(dir, name) {
+			t.Errorf("extractionPath(%q) = %s, want it inside %s", name, destPath, dir)
+		}
+	}
+	for _, name := range []string{"../../etc/passwd", "..", ".", "", "sub/../../model.xml", "../" + filepath.Base(dir) + "x/model.xml"} {
+		if destPath, err := extractionPath(dir, name); err == nil {
+			t.Errorf("extractionPath(%q) = %s, want an error", name, destPath)
+		}
+	}
+}
+
+func TestTraversalEntries(t *testing.T) {
+	input := writeTestArchive(t, "acme-1.0.jar",
+		testEntry{"../../etc/passwd.xml", testModel},
+		testEntry{"../../escape.bpmn20.xml", `<definitions xmlns="http://www.omg.org/spec/BPMN/20100524/MODEL"/>`},
+	)
+	entries := testArchiveEntries(t, extractTest(t, input, Options{Workflows: true}))
+	for _, entry := range entries {
+		if strings.Contains(entry, "..") || strings.HasPrefix(entry, "/") {
+			t.Errorf("output entry %s escapes the module", entry)
+		}
+	}
+	if !slices.Contains(entries, "alfresco/module/acme/model/passwd.xml") {
+		t.Errorf("entries = %v, want the model packaged as passwd.xml", entries)
+	}
+	if !slices.Contains(entries, "alfresco/module/acme/workflow/escape.bpmn20.xml") {
+		t.Errorf("entries = %v, want the workflow packaged as escape.bpmn20.xml", entries)
+	}
+}
+
+// Helper function to build a model padded with pseudo-random words, which
+// compresses differently at each flate level
+func compressibleModel(size int) string {
+	words := []string{"acme", "content", "aspect", "property", "type", "mandatory", "index", "tokenised", "constraint", "association"}
+	var content strings.Builder
+	content.WriteString(strings.TrimSuffix(testModel, "</model>\n"))
+	content.WriteString("  <description>")
+	for seed := uint32(1); content.Len() < size; {
+		seed = seed*1664525 + 1013904223
+		content.WriteString(words[seed>>16%uint32(len(words))])
+		content.WriteByte(" \n"[seed>>8&1])
+	}
+	content.WriteString("</description>\n</model>\n")
+	return content.String()
+}
+
+func TestCompression(t *testing.T) {
+	model := compressibleModel(256 << 10)
+	input := writeTestArchive(t, "acme-1.0.jar", testEntry{"model.xml", model})
+	// Compressed size of the packaged model for each -compression
+	sizes := make(map[string]uint64)
+	for _, compression := range []string{"", "store", "1", "9"} {
+		for _, file := range openTestArchive(t, extractTest(t, input, Options{Compression: compression})).File {
+			if file.Name != testModelPath {
+				continue
+			}
+			if stored := file.Method == zip.Store; stored != (compression == "store") {
+				t.Errorf("-compression %q: model stored = %v", compression, stored)
+			}
+			sizes[compression] = file.CompressedSize64
+		}
+	}
+	if sizes["store"] != uint64(len(model)) {
+		t.Errorf("stored model is %d bytes, want %d", sizes["store"], len(model))
+	}
+	if !(sizes["9"] < sizes[""] && sizes[""] < sizes["1"] && sizes["1"] < sizes["store"]) {
+		t.Errorf("compressed sizes = %v, want level 9 < default < level 1 < store", sizes)
+	}
+}
+
+func TestReadModuleProperties(t *testing.T) {
+	properties := func(id, version string) string {
+		return "module.id=" + id + "\nmodule.version=" + version + "\n"
+	}
+	tests := []struct {
+		name    string
+		entries []testEntry
+		want    moduleProperties
+	}{
+		{"jar", []testEntry{
+			{"alfresco/module/acme/module.properties", properties("acme-platform", "1.2.3")},
+		}, moduleProperties{Name: "acme", ID: "acme-platform", Version: "1.2.3"}},
+		{"amp", []testEntry{
+			{"module.properties", properties("acme-platform", "2.0.0")},
+			{"config/alfresco/module/acme/module-context.xml", "<beans/>"},
+		}, moduleProperties{ID: "acme-platform", Version: "2.0.0"}},
+		{"jar with another module directory", []testEntry{
+			{"alfresco/module/acme-platform/module.properties", properties("acme-platform", "1.0.0")},
+		}, moduleProperties{Name: "acme-platform", ID: "acme-platform", Version: "1.0.0"}},
+		{"jar preferred to the root module.properties", []testEntry{
+			{"module.properties", properties("other", "9.9.9")},
+			{"alfresco/module/acme/module.properties", properties("acme", "1.0.0")},
+		}, moduleProperties{Name: "acme", ID: "acme", Version: "1.0.0"}},
+		{"no module.properties", []testEntry{
+			{"alfresco/module/acme/model/model.xml", testModel},
+		}, moduleProperties{}},
+	}
+	for _, tt := range tests {
+		input := writeTestArchive(t, "acme-1.0.jar", tt.entries...)
+		got, err := readModuleProperties(testLogger, openTestArchive(t, input), "acme", "")
+		if err != nil {
+			t.Errorf("readModuleProperties(%s) failed: %v", tt.name, err)
+		} else if got != tt.want {
+			t.Errorf("readModuleProperties(%s) = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestReadProperties(t *testing.T) {
+	content := `# Module of the Acme content models
+module.id = acme
+module.title   =   Acme models
+#module.version=0.1
+! module.aliases=legacy
+module.description:Models of \
+    the Acme platform
+module.installState INSTALLED
+module.key\=with\:separators=value
+`
+	got, err := readProperties(strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"module.id":                  "acme",
+		"module.title":               "Acme models",
+		"module.description":         "Models of the Acme platform",
+		"module.installState":        "INSTALLED",
+		"module.key=with:separators": "value",
+	}
+	if len(got) != len(want) {
+		t.Errorf("readProperties read %v, want %v", got, want)
+	}
+	for key, value := range want {
+		if got[key] != value {
+			t.Errorf("readProperties()[%q] = %q, want %q", key, got[key], value)
+		}
+	}
+	for _, key := range []string{"module.version", "#module.version", "module.aliases"} {
+		if value, ok := got[key]; ok {
+			t.Errorf("commented-out %s was read as %q", key, value)
+		}
+	}
+}
+
+func TestEscapeProperty(t *testing.T) {
+	tests := []struct {
+		value string
+		key   bool
+		want  string
+	}{
+		{"Acme models", false, "Acme models"},
+		{" leading space", false, "\\ leading space"},
+		{"line one\nline two", false, "line one\\nline two"},
+		{"tab\tcarriage\rfeed\f", false, "tab\\tcarriage\\rfeed\\f"},
+		{"C:\\models", false, "C:\\\\models"},
+		{"acme:model key=1", true, "acme\\:model\\ key\\=1"},
+		{"key\nwith\ttabs", true, "key\\nwith\\ttabs"},
+	}
+	for _, tt := range tests {
+		got := escapeProperty(tt.value, tt.key)
+		if got != tt.want {
+			t.Errorf("escapeProperty(%q, %v) = %q, want %q", tt.value, tt.key, got, tt.want)
+		}
+		// Every escaped line must be read back as the original key or value
+		line := "key=" + got
+		if tt.key {
+			line = got + "=value"
+		}
+		entries, err := readProperties(strings.NewReader(line + "\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.key && entries[tt.value] != "value" || !tt.key && entries["key"] != tt.value {
+			t.Errorf("escapeProperty(%q, %v) is read back as %q", tt.value, tt.key, entries)
+		}
+	}
+}
+
+func TestReadModulePropertiesWindowsLineEndings(t *testing.T) {
+	// module.properties saved by a Windows editor, with a BOM and CRLF line endings
+	content := utf8BOM + "module.id=acme\r\nmodule.version=1.2.3\r\nmodule.title=Acme\r\n"
+	input := writeTestArchive(t, "acme-1.0.jar", testEntry{"alfresco/module/acme/module.properties", content})
+	got, err := readModuleProperties(testLogger, openTestArchive(t, input), "acme", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (moduleProperties{Name: "acme", ID: "acme", Version: "1.2.3"}); got != want {
+		t.Errorf("readModuleProperties = %+v, want %+v", got, want)
+	}
+}
+
+func TestVersionFromWindowsModuleProperties(t *testing.T) {
+	input := writeTestArchive(t, "acme-1.0.jar",
+		testEntry{"alfresco/module/acme/module.properties", utf8BOM + "module.id=acme\r\nmodule.version=1.2.3\r\n"},
+		testEntry{"alfresco/module/acme/model/model.xml", testModel},
+	)
+	properties := readTestEntry(t, extractTest(t, input, Options{}), "alfresco/module/acme/module.properties")
+	if !strings.Contains(properties, "module.version=1.2.4\n") {
+		t.Errorf("module.properties = %q, want module.version=1.2.4", properties)
+	}
+}
+
+func TestModelOrderAcrossArchives(t *testing.T) {
+	// Each archive lists its models in reverse name order, so the output order
+	// is the scan order only if it doesn't depend on which detection ends first
+	var first, second []testEntry
+	var want []string
+	for i := 19; i >= 0; i-- {
+		first = append(first, testEntry{fmt.Sprintf("z%02d-model.xml", i), testModelWithPrefix(fmt.Sprintf("z%02d", i))})
+		want = append(want, fmt.Sprintf("alfresco/module/acme/model/z%02d-model.xml", i))
+	}
+	for i := 19; i >= 0; i-- {
+		second = append(second, testEntry{fmt.Sprintf("a%02d-model.xml", i), testModelWithPrefix(fmt.Sprintf("a%02d", i))})
+		want = append(want, fmt.Sprintf("alfresco/module/acme/model/a%02d-model.xml", i))
+	}
+	acme := writeTestArchive(t, "acme-1.0.jar", first...)
+	other := writeTestArchive(t, "other-1.0.jar", second...)
+	for range 3 {
+		var models []string
+		for _, entry := range testArchiveEntries(t, extractTest(t, acme, Options{Inputs: []string{other}})) {
+			if strings.HasSuffix(entry, "-model.xml") {
+				models = append(models, entry)
+			}
+		}
+		if !slices.Equal(models, want) {
+			t.Fatalf("models = %v, want %v", models, want)
+		}
+	}
+}
+
+func TestWorkflowsSharingABaseName(t *testing.T) {
+	workflow := `<definitions xmlns="http://www.omg.org/spec/BPMN/20100524/MODEL"/>`
+	input := writeTestArchive(t, "acme-1.0.jar",
+		testEntry{"a/review.bpmn20.xml", workflow},
+		testEntry{"b/review.bpmn20.xml", workflow},
+		testEntry{"model.xml", testModel},
+	)
+	output := extractTest(t, input, Options{Workflows: true})
+	count := 0
+	for _, entry := range testArchiveEntries(t, output) {
+		if entry == "alfresco/module/acme/workflow/review.bpmn20.xml" {
+			count++
+		}
+	}
+	if count != 1 {
+		t.Errorf("review.bpmn20.xml is packaged %d times, want once", count)
+	}
+	context := readTestEntry(t, output, "alfresco/module/acme/module-context.xml")
+	if got := strings.Count(context, "workflow/review.bpmn20.xml"); got != 1 {
+		t.Errorf("module-context.xml lists review.bpmn20.xml %d times, want once:\n%s", got, context)
+	}
+}
+
+func TestReportDictionaryVersion(t *testing.T) {
+	input := writeTestArchive(t, "acme-1.0.jar", testEntry{"model.xml", testModel})
+	reportPath := filepath.Join(t.TempDir(), "report.json")
+	extractTest(t, input, Options{Report: reportPath})
+	content, err := os.ReadFile(reportPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reports []modelReport
+	if err := json.Unmarshal(content, &reports); err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 1 {
+		t.Fatalf("report describes %d models, want 1:\n%s", len(reports), content)
+	}
+	if reports[0].DictionaryVersion != "1.0" {
+		t.Errorf("dictionaryVersion = %q, want %q", reports[0].DictionaryVersion, "1.0")
+	}
+}
+
+func TestTimingsOnEarlyReturns(t *testing.T) {
+	input := writeTestArchive(t, "acme-1.0.jar", testEntry{"model.xml", testModel})
+	tests := []struct {
+		name  string
+		opts  Options
+		phase string
+	}{
+		{"dry run", Options{DryRun: true}, "plan"},
+		{"list namespaces", Options{ListNamespaces: true}, "analysis"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Inputs, tt.opts.Timings = []string{input}, true
+			out, _, err := runExtract(t, tt.opts)
+			if err != nil {
+				t.Fatalf("Extract failed: %v\n%s", err, out)
+			}
+			if !strings.Contains(out, tt.phase+" ") || !strings.Contains(out, "total ") {
+				t.Errorf("%s with timings printed no %s and total durations:\n%s", tt.name, tt.phase, out)
+			}
+		})
+	}
+}
+
+func TestDedupeModelsRenamesWithNumericSuffix(t *testing.T) {
+	var files []extractedFile
+	for _, prefix := range []string{"a", "b", "c"} {
+		model, err := parseModelContent([]byte(testModelWithPrefix(prefix)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, extractedFile{Entry: prefix + "/model.xml", Target: "model.xml", Model: model})
+	}
+	kept, err := dedupeModels(testLogger, files, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var targets []string
+	for _, file := range kept {
+		targets = append(targets, file.Target)
+	}
+	if want := []string{"model.xml", "model-2.xml", "model-3.xml"}; !slices.Equal(targets, want) {
+		t.Errorf("dedupeModels targets = %v, want %v", targets, want)
+	}
+
+	if _, err := dedupeModels(testLogger, files, false); err == nil || !strings.Contains(err.Error(), "share the output path model.xml") {
+		t.Errorf("dedupeModels without rename error = %v, want the shared output path", err)
+	}
+}
+
+func TestProgressClearedBeforeLogging(t *testing.T) {
+	input := writeTestArchive(t, "acme-1.0.jar",
+		testEntry{"alfresco/", ""},
+		testEntry{"alfresco/model/", ""},
+		testEntry{"alfresco/model/model.xml", testModel},
+	)
+	out, _, err := runExtract(t, Options{Inputs: []string{input}, Progress: true, LogLevel: "debug"})
+	if err != nil {
+		t.Fatalf("Extract failed: %v\n%s", err, out)
+	}
+	if !strings.Contains(out, "Skipping alfresco/model/, it is a directory entry") {
+		t.Errorf("skipped directory entry is not logged:\n%s", out)
+	}
+	// What is left visible of each line after its last carriage return
+	for _, line := range strings.Split(out, "\n") {
+		visible := line[strings.LastIndex(line, "\r")+1:]
+		if strings.Contains(visible, "models found") && strings.Contains(visible, "Debug:") {
+			t.Errorf("log message runs on after the progress line: %q", visible)
+		}
+	}
+}
+
+func TestOutputsMustNotOverwriteInputs(t *testing.T) {
+	tests := []struct {
+		flag string
+		set  func(opts *Options, path string)
+	}{
+		{"-output", func(opts *Options, path string) { opts.Output = path }},
+		{"-layer", func(opts *Options, path string) { opts.Layer = path }},
+		{"-report", func(opts *Options, path string) { opts.Report = path }},
+		{"-diagram", func(opts *Options, path string) { opts.Diagram = path }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.flag, func(t *testing.T) {
+			input := writeTestArchive(t, "acme-1.0.jar", testEntry{"model.xml", testModel})
+			before, err := os.ReadFile(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			opts := Options{Inputs: []string{input}}
+			tt.set(&opts, input)
+			_, _, err = runExtract(t, opts)
+			if err == nil || !strings.Contains(err.Error(), "is also an input, use "+tt.flag+" to write it elsewhere") {
+				t.Errorf("Extract error = %v, want the overwritten input", err)
+			}
+			if after, err := os.ReadFile(input); err != nil || !slices.Equal(after, before) {
+				t.Errorf("input was modified by %s (%v)", tt.flag, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package extractor
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// Classpath directory of the Alfresco web application in the official images
+const defaultImagePath = "/usr/local/tomcat/webapps/alfresco/WEB-INF/classes"
+
+// Tar implementation of moduleArchive, placing every entry under a prefix
+type tarArchive struct {
+	tarWriter *tar.Writer
+	prefix    string
+	modified  time.Time
+}
+
+func (a tarArchive) createDir(name string) error {
+	return a.tarWriter.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     path.Join(a.prefix, name) + "/",
+		Mode:     0755,
+		ModTime:  a.modified,
+	})
+}
+
+func (a tarArchive) createFile(name string, content []byte, compress bool) error {
+	return a.copyFile(name, bytes.NewReader(content), int64(len(content)), compress)
+}
+
+func (a tarArchive) copyFile(name string, src io.Reader, size int64, compress bool) error {
+	if err := a.tarWriter.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     path.Join(a.prefix, name),
+		Mode:     0644,
+		Size:     size,
+		ModTime:  a.modified,
+	}); err != nil {
+		return err
+	}
+	_, err := io.Copy(a.tarWriter, src)
+	return err
+}
+
+// Function to write the module as a gzip-compressed tarball with the same tree
+// as the module JAR, META-INF included
+func createModuleTarGz(tarGzPath string, layout moduleLayout) error {
+	tarGzFile, err := os.Create(tarGzPath)
+	if err != nil {
+		return err
+	}
+	defer tarGzFile.Close()
+
+	gzipWriter := gzip.NewWriter(tarGzFile)
+	defer gzipWriter.Close()
+
+	tarWriter := tar.NewWriter(gzipWriter)
+	defer tarWriter.Close()
+
+	return writeModule(tarArchive{tarWriter: tarWriter, modified: layout.Modified}, layout, true)
+}
+
+// Function to write the module as a tar layer that unpacks into the Alfresco
+// classpath of a container image (imagePath), ready for a Dockerfile ADD
+func createModuleLayer(layerPath, imagePath string, layout moduleLayout) error {
+	layerFile, err := os.Create(layerPath)
+	if err != nil {
+		return err
+	}
+	defer layerFile.Close()
+
+	tarWriter := tar.NewWriter(layerFile)
+	defer tarWriter.Close()
+
+	// Layer paths are relative to the image root
+	prefix := strings.Trim(path.Clean("/"+strings.ReplaceAll(imagePath, "\\", "/")), "/")
+
+	// Create the image directories leading to the classpath
+	root := tarArchive{tarWriter: tarWriter, modified: layout.Modified}
+	if prefix != "" {
+		dir := ""
+		for _, segment := range strings.Split(prefix, "/") {
+			dir = path.Join(dir, segment)
+			if err := root.createDir(dir); err != nil {
+				return err
+			}
+		}
+	}
+
+	return writeModule(tarArchive{tarWriter: tarWriter, prefix: prefix, modified: layout.Modified}, layout, false)
+}
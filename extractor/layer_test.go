@@ -0,0 +1,57 @@
+package extractor
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// Helper function to list the entry names of a gzip-compressed tarball
+func testTarGzEntries(t testing.TB, tarGzPath string) []string {
+	t.Helper()
+	file, err := os.Open(tarGzPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tarReader := tar.NewReader(gzipReader)
+	var names []string
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return names
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, header.Name)
+	}
+}
+
+func TestCreateModuleTarGz(t *testing.T) {
+	layout := testLayout(t, writeTestModelFile(t))
+	dir := t.TempDir()
+	jarPath, tarGzPath := filepath.Join(dir, "acme.jar"), filepath.Join(dir, "acme.tar.gz")
+	if err := createModuleJar(jarPath, layout); err != nil {
+		t.Fatal(err)
+	}
+	if err := createModuleTarGz(tarGzPath, layout); err != nil {
+		t.Fatal(err)
+	}
+	// The tarball holds the same tree as the JAR, META-INF included
+	entries, want := testTarGzEntries(t, tarGzPath), testArchiveEntries(t, jarPath)
+	if !slices.Equal(entries, want) {
+		t.Errorf("tar.gz entries = %v, want the JAR entries %v", entries, want)
+	}
+	if !slices.Contains(entries, testModelPath) {
+		t.Errorf("tar.gz has no entry %s: %v", testModelPath, entries)
+	}
+}
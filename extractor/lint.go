@@ -0,0 +1,147 @@
+package extractor
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Namespace URI of the Alfresco dictionary model defining the data types
+const dictionaryModelURI = "http://www.alfresco.org/model/dictionary/1.0"
+
+// Problem found by -lint in a model
+type lintIssue struct {
+	Entry   string
+	Message string
+}
+
+func (i lintIssue) String() string {
+	return fmt.Sprintf("%s: %s", i.Entry, i.Message)
+}
+
+// Function to run every lint check on the parsed models
+func lintModels(files []extractedFile) []lintIssue {
+	var issues []lintIssue
+	for _, file := range files {
+		if file.Model == nil {
+			continue
+		}
+		messages := lintDefaultValues(file.Model)
+		if message := lintModelVersion(file.Model); message != "" {
+			messages = append(messages, message)
+		}
+		for _, message := range messages {
+			issues = append(issues, lintIssue{Entry: file.Entry, Message: message})
+		}
+	}
+	return issues
+}
+
+// Patterns for data types without a dedicated parser
+var (
+	qnamePattern   = regexp.MustCompile(`^([A-Za-z_][\w.-]*:)?[A-Za-z_][\w.-]*$`)
+	nodeRefPattern = regexp.MustCompile(`^[a-z]+://[^/]+/.+$`)
+	localePattern  = regexp.MustCompile(`^[a-z]{2,3}(_[A-Z]{2})?(_\w+)?$`)
+)
+
+// Pattern of the numeric model versions expected by the dictionary
+var versionPattern = regexp.MustCompile(`^\d+(\.\d+)*$`)
+
+// Date layouts accepted by the dictionary for d:date and d:datetime defaults
+var dateLayouts = []string{
+	"2006-01-02",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04:05.000",
+	"2006-01-02T15:04:05Z07:00",
+	"2006-01-02T15:04:05.000Z07:00",
+}
+
+// Validators of default values, keyed by dictionary data type local name.
+// Data types missing here (text, content, any, ...) accept any value.
+var defaultValidators = map[string]func(value string) bool{
+	"int": func(value string) bool {
+		_, err := strconv.ParseInt(value, 10, 32)
+		return err == nil
+	},
+	"long": func(value string) bool {
+		_, err := strconv.ParseInt(value, 10, 64)
+		return err == nil
+	},
+	"float": func(value string) bool {
+		_, err := strconv.ParseFloat(value, 32)
+		return err == nil
+	},
+	"double": func(value string) bool {
+		_, err := strconv.ParseFloat(value, 64)
+		return err == nil
+	},
+	"boolean": func(value string) bool {
+		return strings.EqualFold(value, "true") || strings.EqualFold(value, "false")
+	},
+	"date":     isDate,
+	"datetime": isDate,
+	"qname": func(value string) bool {
+		return qnamePattern.MatchString(value)
+	},
+	"noderef": func(value string) bool {
+		return nodeRefPattern.MatchString(value)
+	},
+	"category": func(value string) bool {
+		return nodeRefPattern.MatchString(value)
+	},
+	"locale": func(value string) bool {
+		return localePattern.MatchString(value)
+	},
+}
+
+// Helper function to check a value against the supported date layouts
+func isDate(value string) bool {
+	for _, layout := range dateLayouts {
+		if _, err := time.Parse(layout, value); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Function to check that every property <default> value matches the declared data type
+func lintDefaultValues(model *Model) []string {
+	// Data types are referenced with whatever prefix the dictionary model is imported as
+	dictionaryPrefix := "d"
+	for _, imported := range model.Imports {
+		if imported.URI == dictionaryModelURI {
+			dictionaryPrefix = imported.Prefix
+		}
+	}
+
+	var messages []string
+	classes := append(append([]Class{}, model.Types...), model.Aspects...)
+	for _, class := range classes {
+		for _, property := range class.Properties {
+			value := strings.TrimSpace(property.Default)
+			if value == "" {
+				continue
+			}
+			prefix, dataType, ok := strings.Cut(strings.TrimSpace(property.Type), ":")
+			if !ok || prefix != dictionaryPrefix {
+				continue
+			}
+			if validate, known := defaultValidators[dataType]; known && !validate(value) {
+				messages = append(messages, fmt.Sprintf("property %s of %s has default %q, which is not a valid %s",
+					property.Name, class.Name, value, property.Type))
+			}
+		}
+	}
+	return messages
+}
+
+// Function to check that the model <version> is a simple numeric version, such as 1.0
+func lintModelVersion(model *Model) string {
+	version := strings.TrimSpace(model.Version)
+	if version == "" || versionPattern.MatchString(version) {
+		return ""
+	}
+	return fmt.Sprintf("model version %q is not a numeric version such as 1.0", version)
+}
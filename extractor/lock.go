@@ -0,0 +1,117 @@
+package extractor
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// Function to compute the content hash of every packaged model, keyed by model
+// name. Models that could not be parsed are keyed by their archive entry.
+func modelHashes(files []extractedFile) (map[string]string, error) {
+	hashes := make(map[string]string, len(files))
+	for _, file := range files {
+		content, err := readExtractedFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", file.Entry, err)
+		}
+		key := file.Entry
+		if file.Model != nil && file.Model.Name != "" {
+			key = file.Model.Name
+		}
+		sum := sha256.Sum256(content)
+		hashes[key] = "sha256:" + hex.EncodeToString(sum[:])
+	}
+	return hashes, nil
+}
+
+// Function to read a lock file written by writeLockFile, a properties file
+// mapping model names to content hashes. A missing file is reported with an
+// error matching os.ErrNotExist.
+func readLockFile(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return readProperties(file)
+}
+
+// Function to write the model hashes as a sorted properties file
+func writeLockFile(path string, hashes map[string]string) error {
+	keys := make([]string, 0, len(hashes))
+	for key := range hashes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var buffer bytes.Buffer
+	buffer.WriteString("# Content hash of each packaged model, checked with -lock\n")
+	for _, key := range keys {
+		fmt.Fprintf(&buffer, "%s=%s\n", escapeProperty(key, true), escapeProperty(hashes[key], false))
+	}
+	return os.WriteFile(path, buffer.Bytes(), 0644)
+}
+
+// Function to compare the model hashes with the locked ones, returning one
+// message per added, removed or changed model
+func compareHashes(locked, current map[string]string) []string {
+	var changes []string
+	for name, hash := range current {
+		lockedHash, ok := locked[name]
+		switch {
+		case !ok:
+			changes = append(changes, fmt.Sprintf("%s is not in the lock file", name))
+		case lockedHash != hash:
+			changes = append(changes, fmt.Sprintf("%s changed (locked %s, found %s)", name, lockedHash, hash))
+		}
+	}
+	for name := range locked {
+		if _, ok := current[name]; !ok {
+			changes = append(changes, fmt.Sprintf("%s is locked but no longer packaged", name))
+		}
+	}
+	sort.Strings(changes)
+	return changes
+}
+
+// Function to check the packaged models against a lock file, creating it on the
+// first run and rewriting it when update is set
+func checkLockFile(logs logger, path string, files []extractedFile, update bool) error {
+	current, err := modelHashes(files)
+	if err != nil {
+		return err
+	}
+
+	locked, err := readLockFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		if err := writeLockFile(path, current); err != nil {
+			return fmt.Errorf("failed to write lock file: %v", err)
+		}
+		logs.resultf("Created lock file %s with %d models\n", path, len(current))
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read lock file: %v", err)
+	}
+
+	changes := compareHashes(locked, current)
+	if len(changes) == 0 {
+		return nil
+	}
+	if !update {
+		for _, change := range changes {
+			logs.logf(levelError, "  %s", change)
+		}
+		return fmt.Errorf("%d models differ from lock file %s, use -update-lock to accept the changes", len(changes), path)
+	}
+	if err := writeLockFile(path, current); err != nil {
+		return fmt.Errorf("failed to write lock file: %v", err)
+	}
+	logs.resultf("Updated lock file %s with %d changes\n", path, len(changes))
+	return nil
+}
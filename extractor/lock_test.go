@@ -0,0 +1,27 @@
+package extractor
+
+import (
+	"maps"
+	"path/filepath"
+	"testing"
+)
+
+func TestLockFileRoundTrip(t *testing.T) {
+	hashes := map[string]string{
+		"acme:contentModel":    "sha256:0123456789abcdef",
+		"alfresco/model/x.xml": "sha256:fedcba9876543210",
+		"key with spaces":      " leading space",
+		"a=b:c#d!e":            "back\\slash",
+	}
+	path := filepath.Join(t.TempDir(), "models.lock")
+	if err := writeLockFile(path, hashes); err != nil {
+		t.Fatal(err)
+	}
+	got, err := readLockFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !maps.Equal(got, hashes) {
+		t.Errorf("readLockFile() = %q, want %q", got, hashes)
+	}
+}
@@ -0,0 +1,121 @@
+package extractor
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+)
+
+// Verbosity of the messages printed while processing, selected with -log-level
+type logLevel int
+
+const (
+	levelDebug logLevel = iota
+	levelInfo
+	levelWarn
+	levelError
+)
+
+// Names accepted by -log-level
+var logLevels = map[string]logLevel{
+	"debug": levelDebug,
+	"info":  levelInfo,
+	"warn":  levelWarn,
+	"error": levelError,
+}
+
+// Logger of an extraction run: messages below its level are discarded, fatal
+// errors always print. Each run has its own, so concurrent runs can log at
+// different levels and to different streams.
+type logger struct {
+	level    logLevel
+	log      *log.Logger // Messages and warnings, on the stderr of the run
+	stdout   io.Writer   // Results of the run
+	stderr   io.Writer   // Where the -progress line is written
+	progress *progressLine
+}
+
+// The -progress line last written to stderr, cleared before any other output
+// so that messages don't run on after it
+type progressLine struct {
+	mu    sync.Mutex
+	width int
+}
+
+// Function to create the logger of a -log-level, -quiet keeping only the errors
+// whatever the level says. Messages go to stderr and results to stdout.
+func newLogger(levelName string, quiet bool, stdout, stderr io.Writer) (logger, error) {
+	level, ok := logLevels[levelName]
+	if !ok {
+		return logger{}, fmt.Errorf("invalid -log-level %q: must be debug, info, warn or error", levelName)
+	}
+	if quiet {
+		level = max(level, levelError)
+	}
+	return logger{
+		level:    level,
+		log:      log.New(stderr, "", log.LstdFlags),
+		stdout:   stdout,
+		stderr:   stderr,
+		progress: &progressLine{},
+	}, nil
+}
+
+// Function to log a message to stderr when level is enabled
+func (l logger) logf(level logLevel, format string, args ...any) {
+	if level >= l.level {
+		l.clearProgress(func() { l.log.Printf(format, args...) })
+	}
+}
+
+// Helper function to log processing details, such as every archive entry considered
+func (l logger) debugf(format string, args ...any) {
+	l.logf(levelDebug, "Debug: "+format, args...)
+}
+
+// Helper function to log progress messages
+func (l logger) infof(format string, args ...any) {
+	l.logf(levelInfo, format, args...)
+}
+
+// Helper function to log a problem that doesn't stop the build
+func (l logger) warnf(format string, args ...any) {
+	l.logf(levelWarn, "Warning: "+format, args...)
+}
+
+// Helper function to rewrite the -progress line on stderr, keeping stdout for
+// results. A message ending with a newline leaves the line for good.
+func (l logger) progressf(format string, args ...any) {
+	message := fmt.Sprintf(format, args...)
+	l.progress.mu.Lock()
+	defer l.progress.mu.Unlock()
+	// Pad with spaces when the new line is shorter than the one it replaces
+	padding := max(l.progress.width-len(message), 0)
+	fmt.Fprint(l.stderr, "\r"+message+strings.Repeat(" ", padding))
+	if strings.HasSuffix(message, "\n") {
+		l.progress.width = 0
+	} else {
+		l.progress.width = len(message)
+	}
+}
+
+// Helper function to run write once any -progress line is blanked out; the
+// next progressf call writes it again
+func (l logger) clearProgress(write func()) {
+	l.progress.mu.Lock()
+	defer l.progress.mu.Unlock()
+	if l.progress.width > 0 {
+		fmt.Fprint(l.stderr, "\r"+strings.Repeat(" ", l.progress.width)+"\r")
+		l.progress.width = 0
+	}
+	write()
+}
+
+// Helper function to print the results of the run to stdout, at info level
+func (l logger) resultf(format string, args ...any) {
+	if levelInfo >= l.level {
+		l.clearProgress(func() { fmt.Fprintf(l.stdout, format, args...) })
+	}
+}
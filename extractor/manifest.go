@@ -0,0 +1,100 @@
+package extractor
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Legal manifest header name of the JAR specification: an alphanumeric
+// character followed by alphanumerics, "-" and "_", 70 bytes at most
+var manifestHeaderRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]{0,69}$`)
+
+// Maximum length in bytes of a manifest line, line break excluded
+const manifestLineLength = 72
+
+// Headers of the built-in manifest, which -manifest-entry cannot set
+var generatedManifestHeaders = []string{
+	"Manifest-Version",
+	"Created-By",
+	"Built-By",
+	"Build-Jdk",
+	"Extractor-Version",
+	"Package",
+	"Implementation-Version",
+	"Implementation-Title",
+}
+
+// Function to parse key=value manifest headers into "Name: Value" lines,
+// sorted by name so the manifest is stable. Header names are case
+// insensitive, and a name given more than once keeps its last value.
+func parseManifestEntries(specs []string) ([]string, error) {
+	values := make(map[string]string, len(specs))
+	names := make(map[string]string, len(specs))
+	for _, spec := range specs {
+		name, value, ok := strings.Cut(spec, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("%q is not a key=value pair", spec)
+		}
+		if !manifestHeaderRegex.MatchString(name) {
+			return nil, fmt.Errorf("%q is not a valid manifest header name: use letters, digits, - and _, starting with a letter or digit, up to 70 bytes", name)
+		}
+		for _, generated := range generatedManifestHeaders {
+			if strings.EqualFold(name, generated) {
+				return nil, fmt.Errorf("%s is generated and cannot be set with -manifest-entry", generated)
+			}
+		}
+		if strings.ContainsAny(value, "\r\n\x00") {
+			return nil, fmt.Errorf("value of manifest header %s contains a line break or NUL character", name)
+		}
+		key := strings.ToLower(name)
+		names[key] = name
+		values[key] = value
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	entries := make([]string, 0, len(keys))
+	for _, key := range keys {
+		entries = append(entries, names[key]+": "+values[key])
+	}
+	return entries, nil
+}
+
+// Function to wrap every header of a rendered manifest at 72 bytes, the
+// built-in ones such as Implementation-Title as well as those of
+// -manifest-entry. Continuation lines written by the template are kept.
+func wrapManifest(content []byte) []byte {
+	lines := strings.Split(string(content), "\n")
+	for i, line := range lines {
+		if !strings.HasPrefix(line, " ") {
+			lines[i] = wrapManifestLine(line)
+		}
+	}
+	return []byte(strings.Join(lines, "\n"))
+}
+
+// Helper function to wrap a manifest header at 72 bytes, continuation lines
+// starting with a space, without splitting a multi-byte UTF-8 character
+func wrapManifestLine(line string) string {
+	var wrapped strings.Builder
+	limit := manifestLineLength
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		wrapped.WriteString(line[:cut] + "\n ")
+		line = line[cut:]
+		// The leading space of continuation lines counts towards the limit
+		limit = manifestLineLength - 1
+	}
+	wrapped.WriteString(line)
+	return wrapped.String()
+}
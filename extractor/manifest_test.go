@@ -0,0 +1,87 @@
+package extractor
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestParseManifestEntries(t *testing.T) {
+	got, err := parseManifestEntries([]string{"Git-Commit=abc123", "Build_Url=https://ci/1", "git-commit=def456"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Sorted case-insensitively, the last value of a header winning
+	if want := []string{"Build_Url: https://ci/1", "git-commit: def456"}; !slices.Equal(got, want) {
+		t.Errorf("parseManifestEntries() = %q, want %q", got, want)
+	}
+
+	for _, spec := range []string{
+		"Git-Commit",
+		"=abc123",
+		"Git Commit=abc123",
+		"-Git-Commit=abc123",
+		strings.Repeat("A", 71) + "=abc123",
+		"built-by=someone",
+		"Implementation-Title=other",
+		"Git-Commit=abc\nInjected: header",
+		"Git-Commit=abc\x00",
+	} {
+		if _, err := parseManifestEntries([]string{spec}); err == nil {
+			t.Errorf("parseManifestEntries(%q) succeeded, want an error", spec)
+		}
+	}
+	if _, err := parseManifestEntries([]string{strings.Repeat("A", 70) + "=abc123"}); err != nil {
+		t.Errorf("parseManifestEntries rejected a 70 bytes header name: %v", err)
+	}
+}
+
+func TestWrapManifestLine(t *testing.T) {
+	for _, line := range []string{
+		"Git-Commit: abc123",
+		"Implementation-Title: " + strings.Repeat("a", 50),
+		"Description: " + strings.Repeat("x", 300),
+		"Description: " + strings.Repeat("é", 100),
+	} {
+		wrapped := wrapManifestLine(line)
+		for _, part := range strings.Split(wrapped, "\n") {
+			if len(part) > manifestLineLength {
+				t.Errorf("wrapManifestLine(%q) has a %d bytes line", line, len(part))
+			}
+			if !utf8.ValidString(part) {
+				t.Errorf("wrapManifestLine(%q) splits a UTF-8 character: %q", line, part)
+			}
+		}
+		if unwrapped := strings.ReplaceAll(wrapped, "\n ", ""); unwrapped != line {
+			t.Errorf("wrapManifestLine(%q) unwraps to %q", line, unwrapped)
+		}
+	}
+}
+
+func TestManifestWrapsLongTitle(t *testing.T) {
+	layout := testLayout(t, writeTestModelFile(t))
+	layout.Name = "acme-" + strings.Repeat("contentmodels-", 8)
+	entries, err := parseManifestEntries([]string{"Description=" + strings.Repeat("Acme ", 30)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	layout.ManifestEntries = entries
+	jarPath := filepath.Join(t.TempDir(), "acme.jar")
+	if err := createModuleJar(jarPath, layout); err != nil {
+		t.Fatal(err)
+	}
+	manifest := readTestEntry(t, jarPath, "META-INF/MANIFEST.MF")
+	for _, line := range strings.Split(manifest, "\n") {
+		if len(line) > manifestLineLength {
+			t.Errorf("manifest has a %d bytes line: %q", len(line), line)
+		}
+	}
+	unwrapped := strings.ReplaceAll(manifest, "\n ", "")
+	for _, want := range []string{"Implementation-Title: " + layout.Name + "\n", "Description: " + strings.Repeat("Acme ", 30) + "\n"} {
+		if !strings.Contains(unwrapped, want) {
+			t.Errorf("manifest has no header %q once unwrapped:\n%s", want, manifest)
+		}
+	}
+}
@@ -0,0 +1,351 @@
+package extractor
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Raw view of a model used for merging, keeping definition sections verbatim
+type rawModel struct {
+	Attrs       []xml.Attr  `xml:",any,attr"`
+	Description string      `xml:"description"`
+	Author      string      `xml:"author"`
+	Published   string      `xml:"published"`
+	Version     string      `xml:"version"`
+	Imports     []Namespace `xml:"imports>import"`
+	Namespaces  []Namespace `xml:"namespaces>namespace"`
+	DataTypes   rawSection  `xml:"data-types"`
+	Constraints rawSection  `xml:"constraints"`
+	Types       rawSection  `xml:"types"`
+	Aspects     rawSection  `xml:"aspects"`
+}
+
+// Verbatim content of a model section
+type rawSection struct {
+	Inner string `xml:",innerxml"`
+}
+
+// Model content taking part in a merge
+type mergeSource struct {
+	Entry   string
+	Content []byte
+}
+
+// Function to get the primary namespace of a model: the one whose prefix matches
+// the prefix of the model name, or the first declared namespace otherwise
+func primaryNamespace(model *Model) (Namespace, bool) {
+	if len(model.Namespaces) == 0 {
+		return Namespace{}, false
+	}
+	if prefix, _, ok := strings.Cut(model.Name, ":"); ok {
+		for _, namespace := range model.Namespaces {
+			if namespace.Prefix == prefix {
+				return namespace, true
+			}
+		}
+	}
+	return model.Namespaces[0], true
+}
+
+// Function to replace models sharing the same primary namespace with a single
+// merged model file. Models that can't be grouped are kept as they are.
+func mergeModelFiles(files []extractedFile, tempDir string) ([]extractedFile, error) {
+	// Group models by primary namespace, keeping the order of first appearance
+	groups := make(map[string][]extractedFile)
+	var order []string
+	var merged []extractedFile
+	for _, file := range files {
+		if file.Model == nil {
+			merged = append(merged, file)
+			continue
+		}
+		namespace, ok := primaryNamespace(file.Model)
+		if !ok {
+			merged = append(merged, file)
+			continue
+		}
+		if _, exists := groups[namespace.URI]; !exists {
+			order = append(order, namespace.URI)
+		}
+		groups[namespace.URI] = append(groups[namespace.URI], file)
+	}
+
+	for i, uri := range order {
+		group := groups[uri]
+		if len(group) == 1 {
+			merged = append(merged, group[0])
+			continue
+		}
+
+		sources := make([]mergeSource, 0, len(group))
+		entries := make([]string, 0, len(group))
+		for _, file := range group {
+			content, err := readModelContent(file)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %v", file.Entry, err)
+			}
+			sources = append(sources, mergeSource{Entry: file.Entry, Content: content})
+			entries = append(entries, file.Entry)
+		}
+
+		content, err := mergeModels(group[0].Model.Name, sources)
+		if err != nil {
+			return nil, fmt.Errorf("failed to merge models of namespace %s: %v", uri, err)
+		}
+		model, err := parseModelContent(content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse merged model of namespace %s: %v", uri, err)
+		}
+		file := extractedFile{
+			Entry:      strings.Join(entries, " + "),
+			Convention: group[0].Convention,
+			Target:     group[0].Target,
+			Model:      model,
+			Archive:    group[0].Archive,
+		}
+		if err := storeExtractedFile(&file, tempDir, fmt.Sprintf("merged-%d-%s", i, path.Base(group[0].Target)), content); err != nil {
+			return nil, err
+		}
+		merged = append(merged, file)
+	}
+
+	return merged, nil
+}
+
+// Function to replace every model with a single combined model named name, e.g.
+// acme:combinedModel, written as <local name>.xml. Models are merged in load
+// order, and a prefix declared for different URIs or a QName defined twice
+// aborts the merge.
+func mergeAllModels(files []extractedFile, name, tempDir string) ([]extractedFile, error) {
+	prefix, local, ok := strings.Cut(name, ":")
+	if !ok || !namespacePrefixRegex.MatchString(prefix) || local == "" || strings.ContainsAny(local, ":/\\") {
+		return nil, fmt.Errorf("invalid model name %q: use prefix:name", name)
+	}
+	if len(files) == 0 {
+		return files, nil
+	}
+	for _, file := range files {
+		if file.Model == nil {
+			return nil, fmt.Errorf("%s could not be parsed and cannot be merged", file.Entry)
+		}
+	}
+	ordered, err := modelLoadOrder(files)
+	if err != nil {
+		return nil, err
+	}
+
+	sources := make([]mergeSource, 0, len(ordered))
+	entries := make([]string, 0, len(ordered))
+	for _, file := range ordered {
+		content, err := readModelContent(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", file.Entry, err)
+		}
+		sources = append(sources, mergeSource{Entry: file.Entry, Content: content})
+		entries = append(entries, file.Entry)
+	}
+	content, err := mergeModels(name, sources)
+	if err != nil {
+		return nil, err
+	}
+	model, err := parseModelContent(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse merged model: %v", err)
+	}
+	file := extractedFile{
+		Entry:      strings.Join(entries, " + "),
+		Convention: ordered[0].Convention,
+		Target:     local + ".xml",
+		Model:      model,
+		Archive:    ordered[0].Archive,
+	}
+	if err := storeExtractedFile(&file, tempDir, "merged-"+local+".xml", content); err != nil {
+		return nil, err
+	}
+	return []extractedFile{file}, nil
+}
+
+// Helper function to read the content of an extracted model with its XIncludes resolved
+func readModelContent(file extractedFile) ([]byte, error) {
+	content, err := readExtractedFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if usesXInclude(content) {
+		return resolveXIncludes(content, file.Entry, file.ReadEntry, 0)
+	}
+	return content, nil
+}
+
+// Function to merge several models into a single model document named name.
+// Imports and namespaces are united, definitions are concatenated and any
+// QName defined by more than one source is reported as a collision.
+func mergeModels(name string, sources []mergeSource) ([]byte, error) {
+	var merged rawModel
+	rootNamespaces := make(map[string]string) // prefix ("" for default) -> URI
+	var rootOrder []string
+	namespaceURIs := make(map[string]string) // prefix -> URI of declared namespaces
+	importURIs := make(map[string]string)    // prefix -> URI of imports
+	definedBy := make(map[string]string)     // QName -> entry defining it
+	var dataTypes, constraints, types, aspects strings.Builder
+
+	for _, source := range sources {
+		if err := checkDoctype(source.Content); err != nil {
+			return nil, fmt.Errorf("%s: %v", source.Entry, err)
+		}
+		var raw rawModel
+		if err := newModelDecoder(bytes.NewReader(source.Content)).Decode(&raw); err != nil {
+			return nil, fmt.Errorf("%s: %v", source.Entry, err)
+		}
+		model, err := parseModelContent(source.Content)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", source.Entry, err)
+		}
+
+		// Root namespace bindings must agree, as sections are copied verbatim
+		for _, attr := range raw.Attrs {
+			prefix, ok := "", false
+			if attr.Name.Space == "" && attr.Name.Local == "xmlns" {
+				ok = true
+			} else if attr.Name.Space == "xmlns" {
+				prefix, ok = attr.Name.Local, true
+			}
+			if !ok {
+				continue
+			}
+			if uri, exists := rootNamespaces[prefix]; exists {
+				if uri != attr.Value {
+					return nil, fmt.Errorf("%s binds XML prefix %q to %s, other models bind it to %s", source.Entry, prefix, attr.Value, uri)
+				}
+				continue
+			}
+			rootNamespaces[prefix] = attr.Value
+			rootOrder = append(rootOrder, prefix)
+		}
+
+		// Headers come from the first model providing them
+		if merged.Description == "" {
+			merged.Description = raw.Description
+		}
+		if merged.Author == "" {
+			merged.Author = raw.Author
+		}
+		if merged.Published == "" {
+			merged.Published = raw.Published
+		}
+		if merged.Version == "" {
+			merged.Version = raw.Version
+		}
+
+		for _, namespace := range raw.Namespaces {
+			if uri, exists := namespaceURIs[namespace.Prefix]; exists {
+				if uri != namespace.URI {
+					return nil, fmt.Errorf("namespace prefix %s is declared for %s and %s", namespace.Prefix, uri, namespace.URI)
+				}
+				continue
+			}
+			namespaceURIs[namespace.Prefix] = namespace.URI
+			merged.Namespaces = append(merged.Namespaces, namespace)
+		}
+		for _, imported := range raw.Imports {
+			if uri, exists := importURIs[imported.Prefix]; exists {
+				if uri != imported.URI {
+					return nil, fmt.Errorf("import prefix %s is used for %s and %s", imported.Prefix, uri, imported.URI)
+				}
+				continue
+			}
+			importURIs[imported.Prefix] = imported.URI
+			merged.Imports = append(merged.Imports, imported)
+		}
+
+		// Every definition must have a unique QName in the merged model
+		var names []string
+		for _, constraint := range model.Constraints {
+			names = append(names, constraint.Name)
+		}
+		for _, class := range model.Types {
+			names = append(names, class.Name)
+		}
+		for _, class := range model.Aspects {
+			names = append(names, class.Name)
+		}
+		for _, qname := range names {
+			if entry, exists := definedBy[qname]; exists {
+				return nil, fmt.Errorf("%s is defined in both %s and %s", qname, entry, source.Entry)
+			}
+			definedBy[qname] = source.Entry
+		}
+
+		dataTypes.WriteString(raw.DataTypes.Inner)
+		constraints.WriteString(raw.Constraints.Inner)
+		types.WriteString(raw.Types.Inner)
+		aspects.WriteString(raw.Aspects.Inner)
+	}
+
+	// Namespaces now declared by the merged model itself can't be imported
+	var imports []Namespace
+	for _, imported := range merged.Imports {
+		if uri, declared := namespaceURIs[imported.Prefix]; declared && uri == imported.URI {
+			continue
+		}
+		imports = append(imports, imported)
+	}
+
+	var buffer bytes.Buffer
+	buffer.WriteString("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	fmt.Fprintf(&buffer, "<model name=\"%s\"", xmlEscape(name))
+	for _, prefix := range rootOrder {
+		if prefix == "" {
+			fmt.Fprintf(&buffer, " xmlns=\"%s\"", xmlEscape(rootNamespaces[prefix]))
+		} else {
+			fmt.Fprintf(&buffer, " xmlns:%s=\"%s\"", prefix, xmlEscape(rootNamespaces[prefix]))
+		}
+	}
+	buffer.WriteString(">\n")
+
+	writeElement := func(element, value string) {
+		if value != "" {
+			fmt.Fprintf(&buffer, "    <%s>%s</%s>\n", element, xmlEscape(value), element)
+		}
+	}
+	writeElement("description", merged.Description)
+	writeElement("author", merged.Author)
+	writeElement("published", merged.Published)
+	writeElement("version", merged.Version)
+
+	writeNamespaces := func(section, element string, namespaces []Namespace) {
+		if len(namespaces) == 0 {
+			return
+		}
+		fmt.Fprintf(&buffer, "    <%s>\n", section)
+		for _, namespace := range namespaces {
+			fmt.Fprintf(&buffer, "        <%s uri=\"%s\" prefix=\"%s\"/>\n", element, xmlEscape(namespace.URI), xmlEscape(namespace.Prefix))
+		}
+		fmt.Fprintf(&buffer, "    </%s>\n", section)
+	}
+	writeNamespaces("imports", "import", imports)
+	writeNamespaces("namespaces", "namespace", merged.Namespaces)
+
+	writeSection := func(section, inner string) {
+		if strings.TrimSpace(inner) != "" {
+			fmt.Fprintf(&buffer, "    <%s>%s</%s>\n", section, inner, section)
+		}
+	}
+	writeSection("data-types", dataTypes.String())
+	writeSection("constraints", constraints.String())
+	writeSection("types", types.String())
+	writeSection("aspects", aspects.String())
+
+	buffer.WriteString("</model>\n")
+	return buffer.Bytes(), nil
+}
+
+// Helper function to escape text for XML content and attribute values
+func xmlEscape(value string) string {
+	var buffer bytes.Buffer
+	xml.EscapeText(&buffer, []byte(value))
+	return buffer.String()
+}
@@ -0,0 +1,111 @@
+package extractor
+
+import (
+	"strings"
+	"testing"
+)
+
+// Helper function to build a model named name declaring the namespace prefix
+// for uri, with a type and an aspect of each given local name
+func testMergeModel(name, prefix, uri string, types, aspects []string) string {
+	var model strings.Builder
+	model.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<model name="` + name + `" xmlns="http://www.alfresco.org/model/dictionary/1.0">
+  <namespaces>
+    <namespace uri="` + uri + `" prefix="` + prefix + `"/>
+  </namespaces>
+`)
+	if len(types) > 0 {
+		model.WriteString("  <types>\n")
+		for _, local := range types {
+			model.WriteString(`    <type name="` + prefix + ":" + local + `"><parent>cm:content</parent></type>` + "\n")
+		}
+		model.WriteString("  </types>\n")
+	}
+	if len(aspects) > 0 {
+		model.WriteString("  <aspects>\n")
+		for _, local := range aspects {
+			model.WriteString(`    <aspect name="` + prefix + ":" + local + `"/>` + "\n")
+		}
+		model.WriteString("  </aspects>\n")
+	}
+	model.WriteString("</model>\n")
+	return model.String()
+}
+
+func TestMergeModels(t *testing.T) {
+	acme := testNamespaceURI("acme")
+	tests := []struct {
+		name    string
+		sources []mergeSource
+		wantErr string
+	}{
+		{
+			name: "distinct definitions",
+			sources: []mergeSource{
+				{"a.xml", []byte(testMergeModel("acme:a", "acme", acme, []string{"invoice"}, []string{"audited"}))},
+				{"b.xml", []byte(testMergeModel("acme:b", "acme", acme, []string{"contract"}, []string{"signed"}))},
+			},
+		},
+		{
+			name: "same type",
+			sources: []mergeSource{
+				{"a.xml", []byte(testMergeModel("acme:a", "acme", acme, []string{"invoice"}, nil))},
+				{"b.xml", []byte(testMergeModel("acme:b", "acme", acme, []string{"invoice"}, nil))},
+			},
+			wantErr: "acme:invoice is defined in both a.xml and b.xml",
+		},
+		{
+			name: "same aspect",
+			sources: []mergeSource{
+				{"a.xml", []byte(testMergeModel("acme:a", "acme", acme, nil, []string{"audited"}))},
+				{"b.xml", []byte(testMergeModel("acme:b", "acme", acme, nil, []string{"audited"}))},
+			},
+			wantErr: "acme:audited is defined in both a.xml and b.xml",
+		},
+		{
+			name: "prefix declared for different URIs",
+			sources: []mergeSource{
+				{"a.xml", []byte(testMergeModel("acme:a", "acme", acme, []string{"invoice"}, nil))},
+				{"b.xml", []byte(testMergeModel("acme:b", "acme", testNamespaceURI("other"), []string{"contract"}, nil))},
+			},
+			wantErr: "namespace prefix acme is declared for " + acme + " and " + testNamespaceURI("other"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content, err := mergeModels("acme:combinedModel", tt.sources)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("mergeModels() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			model, err := parseModelContent(content)
+			if err != nil {
+				t.Fatalf("merged model can't be parsed: %v\n%s", err, content)
+			}
+			if model.Name != "acme:combinedModel" || len(model.Namespaces) != 1 || len(model.Types) != 2 || len(model.Aspects) != 2 {
+				t.Errorf("merged model = %s with %d namespaces, %d types and %d aspects, want acme:combinedModel with 1, 2 and 2:\n%s",
+					model.Name, len(model.Namespaces), len(model.Types), len(model.Aspects), content)
+			}
+		})
+	}
+}
+
+func TestMergeCollisions(t *testing.T) {
+	acme := testNamespaceURI("acme")
+	input := writeTestArchive(t, "acme-1.0.jar",
+		testEntry{"a-model.xml", testMergeModel("acme:a", "acme", acme, []string{"invoice"}, nil)},
+		testEntry{"b-model.xml", testMergeModel("acme:b", "acme", acme, []string{"invoice"}, nil)},
+	)
+	for _, opts := range []Options{{MergeModels: true}, {Merge: "acme:combinedModel"}} {
+		opts.Inputs = []string{input}
+		if _, _, err := runExtract(t, opts); err == nil || !strings.Contains(err.Error(), "acme:invoice is defined in both a-model.xml and b-model.xml") {
+			t.Errorf("Extract(MergeModels %v, Merge %q) error = %v, want the colliding type", opts.MergeModels, opts.Merge, err)
+		}
+	}
+}
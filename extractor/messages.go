@@ -0,0 +1,49 @@
+package extractor
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Locale suffix of a resource bundle file name, e.g. _fr or _pt_BR
+var bundleLocaleRegex = regexp.MustCompile(`_[a-z]{2,3}(_[A-Z]{2}|_[0-9]{3})?$`)
+
+// Function to detect a message bundle: a .properties file inside a messages directory
+func isMessageBundle(entryName string) bool {
+	return strings.HasSuffix(strings.ToLower(entryName), ".properties") &&
+		strings.Contains("/"+path.Dir(entryName)+"/", "/messages/")
+}
+
+// Helper function to build the JAR entry path of a packaged message bundle
+func messageEntryPath(moduleDir string, file extractedFile) string {
+	return fmt.Sprintf("%s/messages/%s", moduleDir, file.Target)
+}
+
+// Function to get the resource bundle base names of the packaged message files,
+// as registered with Alfresco: without the .properties extension and the
+// locale suffix, so content-model_fr.properties and content-model.properties
+// are the same content-model bundle. A suffix is only taken for a locale when
+// the file without it is packaged too, as acme_app.properties is not the app
+// locale of an acme bundle.
+func messageBundleNames(moduleDir string, files []extractedFile) []string {
+	packaged := make(map[string]bool, len(files))
+	for _, file := range files {
+		packaged[strings.TrimSuffix(messageEntryPath(moduleDir, file), path.Ext(file.Target))] = true
+	}
+	var names []string
+	for _, file := range files {
+		name := strings.TrimSuffix(messageEntryPath(moduleDir, file), path.Ext(file.Target))
+		if base := bundleLocaleRegex.ReplaceAllString(name, ""); packaged[base] {
+			name = base
+		}
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
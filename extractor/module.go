@@ -0,0 +1,1662 @@
+package extractor
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"golang.org/x/text/encoding"
+)
+
+// Simple XML structure to check for model declaration
+type Model struct {
+	XMLName     xml.Name     `xml:"model"`
+	Name        string       `xml:"name,attr"`
+	Version     string       `xml:"version"`
+	Imports     []Namespace  `xml:"imports>import"`
+	Namespaces  []Namespace  `xml:"namespaces>namespace"`
+	Constraints []Constraint `xml:"constraints>constraint"`
+	Types       []Class      `xml:"types>type"`
+	Aspects     []Class      `xml:"aspects>aspect"`
+}
+
+// Constraint defined at model level
+type Constraint struct {
+	Name string `xml:"name,attr"`
+	Type string `xml:"type,attr"`
+}
+
+// Namespace declared or imported by a model
+type Namespace struct {
+	URI    string `xml:"uri,attr" json:"uri"`
+	Prefix string `xml:"prefix,attr" json:"prefix"`
+}
+
+// Type or aspect definition
+type Class struct {
+	Name              string        `xml:"name,attr"`
+	Parent            string        `xml:"parent"`
+	Properties        []Property    `xml:"properties>property"`
+	Associations      []Association `xml:"associations>association"`
+	ChildAssociations []Association `xml:"associations>child-association"`
+	MandatoryAspects  []string      `xml:"mandatory-aspects>aspect"`
+}
+
+// Property of a type or aspect
+type Property struct {
+	Name    string `xml:"name,attr"`
+	Type    string `xml:"type"`
+	Default string `xml:"default"`
+}
+
+// Association (peer or child) of a type or aspect
+type Association struct {
+	Name   string `xml:"name,attr"`
+	Target string `xml:"target>class"`
+}
+
+// File extracted from the source archive
+type extractedFile struct {
+	Entry      string // Entry name inside the source archive
+	Path       string // Location of the extracted copy, empty with -dry-run
+	Content    []byte // Content kept in memory with -dry-run, which writes no copy
+	Convention string // Classpath convention the entry was found under
+	Target     string // Path relative to the target directory in the JAR
+	Model      *Model // Parsed model, nil when parsing failed
+	Archive    string // Input archive the entry comes from
+	// Reads other entries of the source archive, e.g. to resolve XIncludes
+	ReadEntry func(name string) ([]byte, error)
+}
+
+// Input archive opened for scanning
+type inputArchive struct {
+	Path       string // File path, or outer!/entry for an archive nested in another one
+	Reader     *zip.Reader
+	Closer     io.Closer // Closes the archive file, nil for nested archives read in memory
+	IsWar      bool
+	TrimPrefix string
+	Module     moduleProperties // Module declared by its module.properties, empty when missing
+	Skipped    int              // Entries skipped while opening it, e.g. an unreadable module.properties
+}
+
+// Module declared by the module.properties of an archive
+type moduleProperties struct {
+	Name    string // Directory of alfresco/module/<name>/, empty for the root module.properties of an AMP
+	ID      string // module.id
+	Version string // module.version
+	Source  string // Entry the module was read from when it isn't a module.properties
+}
+
+// Templates for generated files
+const modulePropertiesTmpl = `module.id={{.ID}}
+module.title={{.Title}}
+module.description={{.Description}}
+module.version={{.Version}}
+{{- if .InstallState}}
+module.installState={{.InstallState}}
+{{- end}}
+{{- if .Aliases}}
+module.aliases={{.Aliases}}
+{{- end}}
+{{- range .Properties}}
+{{.Key}}={{.Value}}
+{{- end}}
+`
+
+const moduleContextXmlTmpl = `<?xml version='1.0' encoding='UTF-8'?>
+<beans xmlns="http://www.springframework.org/schema/beans"
+       xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance"
+       xsi:schemaLocation="http://www.springframework.org/schema/beans
+          http://www.springframework.org/schema/beans/spring-beans-3.0.xsd">
+    <bean id="{{.Name}}" parent="dictionaryModelBootstrap" depends-on="dictionaryBootstrap">
+        <property name="models">
+            <list>
+                {{- range .ModelPaths}}
+                <value>{{.}}</value>
+                {{- end}}
+            </list>
+        </property>
+    </bean>
+    {{- if .WorkflowPaths}}
+    <bean id="{{.Name}}.workflowBootstrap" parent="workflowDeployer">
+        <property name="workflowDefinitions">
+            <list>
+                {{- range .WorkflowPaths}}
+                <props>
+                    <prop key="engineId">activiti</prop>
+                    <prop key="location">{{.}}</prop>
+                    <prop key="mimetype">text/xml</prop>
+                    <prop key="redeploy">false</prop>
+                </props>
+                {{- end}}
+            </list>
+        </property>
+    </bean>
+    {{- end}}
+    {{- if .MessageBundles}}
+    <bean id="{{.Name}}.messageBootstrap" class="org.alfresco.i18n.ResourceBundleBootstrapComponent">
+        <property name="resourceBundles">
+            <list>
+                {{- range .MessageBundles}}
+                <value>{{.}}</value>
+                {{- end}}
+            </list>
+        </property>
+    </bean>
+    {{- end}}
+</beans>`
+
+// Share has no data dictionary to bootstrap models into: the models are packaged
+// as classpath resources for the Share configuration to use, and only the message
+// bundles are registered, with the Surf resource bundle component. The model
+// paths are listed in the description, as a path holding -- would end a comment.
+const shareContextXmlTmpl = `<?xml version='1.0' encoding='UTF-8'?>
+<beans xmlns="http://www.springframework.org/schema/beans"
+       xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance"
+       xsi:schemaLocation="http://www.springframework.org/schema/beans
+          http://www.springframework.org/schema/beans/spring-beans-3.0.xsd">
+    {{- if .ModelPaths}}
+    <description>Models packaged for the Share configuration:
+        {{- range .ModelPaths}}
+        {{html .}}
+        {{- end}}
+    </description>
+    {{- end}}
+    {{- if .MessageBundles}}
+    <bean id="{{.Name}}.resources" class="org.springframework.extensions.surf.util.ResourceBundleBootstrapComponent">
+        <property name="resourceBundles">
+            <list>
+                {{- range .MessageBundles}}
+                <value>{{.}}</value>
+                {{- end}}
+            </list>
+        </property>
+    </bean>
+    {{- end}}
+</beans>`
+
+const shareImportXmlTmpl = `<?xml version='1.0' encoding='UTF-8'?>
+<beans xmlns="http://www.springframework.org/schema/beans"
+       xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance"
+       xsi:schemaLocation="http://www.springframework.org/schema/beans
+          http://www.springframework.org/schema/beans/spring-beans-3.0.xsd">
+    <import resource="classpath:{{.ContextPath}}"/>
+</beans>`
+
+const manifestTmpl = `Manifest-Version: 1.0
+Created-By: Alfresco Model Extractor {{.ToolVersion}}
+Built-By: {{.BuiltBy}}
+Build-Jdk: {{.BuildJdk}}
+Extractor-Version: {{.ToolVersion}}
+Package: org.alfresco.module
+Implementation-Version: {{.Version}}
+Implementation-Title: {{.Name}}
+{{- range .ManifestEntries}}
+{{.}}
+{{- end}}
+
+`
+
+type ModuleData struct {
+	Name           string
+	ID             string
+	Title          string
+	Description    string
+	Version        string
+	BuiltBy        string
+	BuildJdk       string // Go version the extractor was built with, no JDK being involved
+	ToolVersion    string
+	ModelPaths     []string
+	WorkflowPaths  []string
+	MessageBundles []string
+	ContextPath    string // Entry path of module-context.xml
+	InstallState   string
+	Aliases        string
+	Properties     []moduleProperty
+	// Additional "Name: Value" manifest headers, wrapped at 72 bytes with the
+	// rest of the manifest once it is rendered
+	ManifestEntries []string
+}
+
+// Additional module.properties entry, with key and value already escaped
+type moduleProperty struct {
+	Key   string
+	Value string
+}
+
+// Templates used to render the generated module files
+type moduleTemplates struct {
+	properties *template.Template
+	context    *template.Template
+	manifest   *template.Template
+	// Context file of alfresco/web-extension importing module-context.xml, and
+	// extension module declaring the module to Share, with -tier share
+	shareImport    *template.Template
+	shareExtension *template.Template
+}
+
+// File names looked up in the -templates directory
+const (
+	propertiesTmplFile  = "module.properties.tmpl"
+	contextTmplFile     = "module-context.xml.tmpl"
+	manifestTmplFile    = "manifest.tmpl"
+	shareImportTmplFile = "share-context.xml.tmpl"
+	shareExtensionFile  = "extension-module.xml.tmpl"
+)
+
+// Function to load the templates, using the files found in dir as overrides
+// for the built-in ones. An empty dir means built-in templates only. A non-empty
+// contextFile replaces the module-context.xml template of dir and the built-in
+// one, which depends on the tier.
+func loadTemplates(dir, contextFile, tier string) (*moduleTemplates, error) {
+	properties, err := loadTemplate(dir, propertiesTmplFile, modulePropertiesTmpl)
+	if err != nil {
+		return nil, err
+	}
+	var context *template.Template
+	if contextFile != "" {
+		context, err = loadTemplateFile(contextFile)
+	} else {
+		defaultContext := moduleContextXmlTmpl
+		if tier == "share" {
+			defaultContext = shareContextXmlTmpl
+		}
+		context, err = loadTemplate(dir, contextTmplFile, defaultContext)
+	}
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := loadTemplate(dir, manifestTmplFile, manifestTmpl)
+	if err != nil {
+		return nil, err
+	}
+	shareImport, err := loadTemplate(dir, shareImportTmplFile, shareImportXmlTmpl)
+	if err != nil {
+		return nil, err
+	}
+	shareExtension, err := loadTemplate(dir, shareExtensionFile, shareExtensionModuleTmpl)
+	if err != nil {
+		return nil, err
+	}
+	return &moduleTemplates{properties: properties, context: context, manifest: manifest, shareImport: shareImport, shareExtension: shareExtension}, nil
+}
+
+// Helper function to parse a single template, preferring dir/name over the default
+func loadTemplate(dir, name, defaultText string) (*template.Template, error) {
+	text := defaultText
+	if dir != "" {
+		path := filepath.Join(dir, name)
+		content, err := os.ReadFile(path)
+		if err == nil {
+			text = string(content)
+		} else if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read template %s: %v", path, err)
+		}
+	}
+	tmpl, err := template.New(name).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template %s: %v", name, err)
+	}
+	return tmpl, nil
+}
+
+// Helper function to parse a template file that must exist
+func loadTemplateFile(path string) (*template.Template, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template %s: %v", path, err)
+	}
+	tmpl, err := template.New(filepath.Base(path)).Parse(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template %s: %v", path, err)
+	}
+	return tmpl, nil
+}
+
+// Helper function to render a template into a byte slice
+func renderTemplate(tmpl *template.Template, data ModuleData) ([]byte, error) {
+	var buffer bytes.Buffer
+	if err := tmpl.Execute(&buffer, data); err != nil {
+		return nil, fmt.Errorf("failed to render template %s: %v", tmpl.Name(), err)
+	}
+	return buffer.Bytes(), nil
+}
+
+// Pattern of the module.properties of a repository JAR, capturing the module directory
+var jarPropertiesRegex = regexp.MustCompile(`^alfresco/module/([^/]+)/module\.properties$`)
+
+// Function to extract and parse module.properties from ZIP. A repository JAR
+// keeps it under alfresco/module/<name>/, while an AMP keeps it at its root.
+// The module directory may not match moduleName, the name derived from the
+// file name, so the only alfresco/module/*/module.properties is used otherwise.
+func readModuleProperties(logs logger, zipReader *zip.Reader, moduleName, trimPrefix string) (moduleProperties, error) {
+	var expected, root *zip.File
+	var others []*zip.File
+	var otherNames []string
+	for _, file := range zipReader.File {
+		name := trimEntryPrefix(file.Name, trimPrefix)
+		if name == "module.properties" {
+			root = file
+		} else if match := jarPropertiesRegex.FindStringSubmatch(name); match != nil {
+			if match[1] == moduleName {
+				expected = file
+			} else {
+				others = append(others, file)
+				otherNames = append(otherNames, match[1])
+			}
+		}
+	}
+
+	var module moduleProperties
+	properties := expected
+	switch {
+	case expected != nil:
+		module.Name = moduleName
+	case len(others) == 1:
+		properties, module.Name = others[0], otherNames[0]
+	case len(others) > 1:
+		logs.warnf("Several modules found (%s), none named %s", strings.Join(otherNames, ", "), moduleName)
+		properties = root
+	default:
+		properties = root
+	}
+	if properties == nil {
+		return moduleProperties{}, nil // No version if not found, callers apply the default
+	}
+
+	rc, err := properties.Open()
+	if err != nil {
+		return moduleProperties{}, err
+	}
+	defer rc.Close()
+
+	entries, err := readProperties(rc)
+	if err != nil {
+		return moduleProperties{}, err
+	}
+	module.ID = entries["module.id"]
+	module.Version = entries["module.version"]
+	return module, nil
+}
+
+// Function to normalize the -trim-prefix value so it always ends with a slash
+func normalizeTrimPrefix(prefix string) string {
+	prefix = strings.TrimPrefix(strings.ReplaceAll(prefix, "\\", "/"), "/")
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return prefix
+}
+
+// Classpath root of a web application archive
+const warClassesPrefix = "WEB-INF/classes/"
+
+// Function to detect a WAR, either by its extension or by its WEB-INF structure
+func isWarArchive(archivePath string, reader *zip.Reader) bool {
+	if strings.EqualFold(filepath.Ext(archivePath), ".war") {
+		return true
+	}
+	for _, file := range reader.File {
+		if file.Name == "WEB-INF/web.xml" {
+			return true
+		}
+	}
+	return false
+}
+
+// Helper function to split the -zip values, which may be comma-separated lists
+func splitInputs(values []string) []string {
+	var inputs []string
+	for _, value := range values {
+		for _, input := range strings.Split(value, ",") {
+			if input = strings.TrimSpace(input); input != "" {
+				inputs = append(inputs, input)
+			}
+		}
+	}
+	return inputs
+}
+
+// Default version of a module whose inputs provide no module.properties
+const DefaultModuleVersion = "1.0.0"
+
+// Function to get the module version of the inputs. Every input providing a
+// module.properties must agree on it; an empty version is returned when none does.
+func inputsVersion(archives []inputArchive) (string, error) {
+	version, source := "", ""
+	for _, archive := range archives {
+		if archive.Module.Version == "" {
+			continue
+		}
+		if version == "" {
+			version, source = archive.Module.Version, archive.Path
+		} else if archive.Module.Version != version {
+			return "", fmt.Errorf("inputs disagree on module version: %s has %s, %s has %s",
+				source, version, archive.Path, archive.Module.Version)
+		}
+	}
+	return version, nil
+}
+
+// Function to expand the inputs containing wildcards into the paths they match,
+// sorted. A pattern matching nothing is an error; other inputs are kept as given.
+func expandGlobs(inputs []string) ([]string, error) {
+	var paths []string
+	for _, input := range inputs {
+		if input == stdinInput || !strings.ContainsAny(input, "*?[") {
+			paths = append(paths, input)
+			continue
+		}
+		matches, err := filepath.Glob(input)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %s: %v", input, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("pattern %s matches no files", input)
+		}
+		paths = append(paths, matches...)
+	}
+	return paths, nil
+}
+
+// Pattern of the dotted versions expected for a module, with optional pre-release and build metadata
+var dottedVersionRegex = regexp.MustCompile(`^\d+(\.\d+)*([-+].+)?$`)
+
+// Extensions of the archives processed when walking a directory with -recursive
+var archiveExtensions = []string{".zip", ".amp", ".jar"}
+
+// Function to expand the inputs into archive paths, walking directories when
+// recursive is set. The output JAR is never taken as an input.
+func expandInputs(inputs []string, recursive bool, outputPath string) ([]string, error) {
+	outputAbs, _ := filepath.Abs(outputPath)
+	var paths []string
+	for _, input := range inputs {
+		if input == stdinInput {
+			paths = append(paths, input)
+			continue
+		}
+		info, err := os.Stat(input)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			paths = append(paths, input)
+			continue
+		}
+		if !recursive {
+			return nil, fmt.Errorf("%s is a directory, use -recursive to process the archives it contains", input)
+		}
+		err = filepath.WalkDir(input, func(name string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if entry.IsDir() || !slices.Contains(archiveExtensions, strings.ToLower(filepath.Ext(name))) {
+				return nil
+			}
+			if abs, _ := filepath.Abs(name); abs == outputAbs {
+				return nil
+			}
+			paths = append(paths, name)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to walk %s: %v", input, err)
+		}
+	}
+	return paths, nil
+}
+
+// Function to make sure the output written with flag, e.g. -output, doesn't
+// overwrite one of the input archives, comparing absolute paths and, for
+// existing files, their identity so that symbolic and hard links are caught too
+func checkOutputNotInput(flag, outputPath string, archivePaths []string) error {
+	outputAbs, _ := filepath.Abs(outputPath)
+	outputInfo, outputErr := os.Stat(outputPath)
+	for _, archivePath := range archivePaths {
+		if archivePath == stdinInput {
+			continue
+		}
+		if abs, _ := filepath.Abs(archivePath); abs == outputAbs {
+			return fmt.Errorf("output %s is also an input, use %s to write it elsewhere", outputPath, flag)
+		}
+		if outputErr != nil {
+			continue
+		}
+		if info, err := os.Stat(archivePath); err == nil && os.SameFile(info, outputInfo) {
+			return fmt.Errorf("output %s is the same file as input %s, use %s to write it elsewhere", outputPath, archivePath, flag)
+		}
+	}
+	return nil
+}
+
+// Function to open an input archive, decoding its entry names, detecting
+// whether it is a WAR and reading the module.properties of the module it contains
+func openInputArchive(logs logger, archivePath, trimPrefix string, entryNameEncoding encoding.Encoding) (inputArchive, error) {
+	readCloser, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return inputArchive{}, err
+	}
+	archive, err := newInputArchive(logs, archivePath, &readCloser.Reader, trimPrefix, entryNameEncoding)
+	if err != nil {
+		readCloser.Close()
+		return inputArchive{}, err
+	}
+	archive.Closer = readCloser
+
+	// Get current version and module from module.properties
+	archive.Module, archive.Skipped = readArchiveModule(logs, archive, cleanModuleName(archivePath))
+	return archive, nil
+}
+
+// Input name reading the archive from stdin
+const stdinInput = "-"
+
+// Function to open an input archive piped to stdin. zip.Reader needs random
+// access, so the archive is buffered in memory; as there is no file name,
+// moduleName locates its module.properties.
+func openStdinArchive(logs logger, stdin io.Reader, moduleName, trimPrefix string, entryNameEncoding encoding.Encoding) (inputArchive, error) {
+	content, err := io.ReadAll(stdin)
+	if err != nil {
+		return inputArchive{}, fmt.Errorf("failed to read stdin: %v", err)
+	}
+	reader, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return inputArchive{}, fmt.Errorf("stdin is not a valid ZIP archive: %v", err)
+	}
+	archive, err := newInputArchive(logs, "stdin", reader, trimPrefix, entryNameEncoding)
+	if err != nil {
+		return inputArchive{}, err
+	}
+	archive.Module, archive.Skipped = readArchiveModule(logs, archive, moduleName)
+	return archive, nil
+}
+
+// Helper function to read the module.properties of an archive, warning and
+// returning an empty module when it cannot be read, along with the number of
+// entries skipped
+func readArchiveModule(logs logger, archive inputArchive, moduleName string) (moduleProperties, int) {
+	module, err := readModuleProperties(logs, archive.Reader, moduleName, archive.TrimPrefix)
+	if err != nil {
+		logs.warnf("Could not read current version of %s: %v", archive.Path, err)
+		return moduleProperties{}, 1
+	}
+	return module, 0
+}
+
+// Helper function to prepare an opened archive for scanning: decoding entry
+// names and detecting a WAR, which only provides models from WEB-INF/classes
+func newInputArchive(logs logger, archivePath string, reader *zip.Reader, trimPrefix string, entryNameEncoding encoding.Encoding) (inputArchive, error) {
+	archive := inputArchive{Path: archivePath, Reader: reader, TrimPrefix: trimPrefix}
+
+	// Entry names written by legacy tools in another code page must be decoded
+	// before they are matched and classified
+	if entryNameEncoding != nil {
+		if err := decodeEntryNames(reader.File, entryNameEncoding); err != nil {
+			return inputArchive{}, fmt.Errorf("failed to decode entry names of %s: %v", archivePath, err)
+		}
+	}
+
+	// A WAR only provides classpath resources from WEB-INF/classes
+	archive.IsWar = isWarArchive(archivePath, reader)
+	if archive.IsWar {
+		if archive.TrimPrefix == "" {
+			archive.TrimPrefix = warClassesPrefix
+		}
+		logs.infof("Detected WAR archive %s, scanning %s for models", archivePath, archive.TrimPrefix)
+	}
+	return archive, nil
+}
+
+// Helper function to strip the common prefix from an archive entry name
+func trimEntryPrefix(name, prefix string) string {
+	return strings.TrimPrefix(name, prefix)
+}
+
+// Version components that -bump can increment
+var bumpSegments = map[string]int{"major": 0, "minor": 1, "patch": -1}
+
+// Function to increment version. The bumped component (major, minor or patch,
+// the last segment) is incremented and the lower ones reset to zero. SemVer
+// pre-release (-RC1) and build metadata (+sha.abc) are kept, except a -SNAPSHOT
+// pre-release, which is dropped unless keepSnapshot is set.
+func incrementVersion(version, bump string, keepSnapshot bool) string {
+	core, build, hasBuild := strings.Cut(version, "+")
+	core, preRelease, hasPreRelease := strings.Cut(core, "-")
+	if hasPreRelease && strings.EqualFold(preRelease, "SNAPSHOT") && !keepSnapshot {
+		hasPreRelease = false
+	}
+
+	parts := strings.Split(core, ".")
+	if len(parts) < 3 {
+		// If version is incomplete, pad with zeros
+		for len(parts) < 3 {
+			parts = append(parts, "0")
+		}
+	}
+
+	// Try to increment the bumped number, the last one for patch
+	segment := bumpSegments[bump]
+	if segment < 0 {
+		segment = len(parts) - 1
+	}
+	if num, err := strconv.Atoi(parts[segment]); err == nil {
+		parts[segment] = strconv.Itoa(num + 1)
+		for i := segment + 1; i < len(parts); i++ {
+			parts[i] = "0"
+		}
+	} else {
+		// If parsing fails, append .1
+		parts = append(parts, "1")
+	}
+
+	incremented := strings.Join(parts, ".")
+	if hasPreRelease {
+		incremented += "-" + preRelease
+	}
+	if hasBuild {
+		incremented += "+" + build
+	}
+	return incremented
+}
+
+// Function to append a build number to a version, either as an additional
+// segment (1.2.3.45) or as SemVer build metadata (1.2.3+45)
+func appendBuildNumber(version, buildNumber, style string) string {
+	if style == "metadata" {
+		// Build metadata already present is extended with a new identifier
+		if strings.Contains(version, "+") {
+			return version + "." + buildNumber
+		}
+		return version + "+" + buildNumber
+	}
+	return version + "." + buildNumber
+}
+
+// Keys of module.properties written from dedicated flags, which -prop cannot set
+var generatedPropertyKeys = map[string]string{
+	"module.id":           "-id",
+	"module.title":        "-title",
+	"module.description":  "-description",
+	"module.version":      "-version",
+	"module.installState": "-install-state",
+	"module.aliases":      "-aliases",
+}
+
+// Function to parse key=value module.properties entries, sorted by key so the
+// rendered file is stable. A key given more than once keeps its last value.
+func parseModuleProperties(specs []string) ([]moduleProperty, error) {
+	values := make(map[string]string, len(specs))
+	for _, spec := range specs {
+		key, value, ok := strings.Cut(spec, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("%q is not a key=value pair", spec)
+		}
+		if dedicated, generated := generatedPropertyKeys[key]; generated {
+			return nil, fmt.Errorf("%s is generated, use %s to set it", key, dedicated)
+		}
+		values[key] = value
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	properties := make([]moduleProperty, 0, len(keys))
+	for _, key := range keys {
+		properties = append(properties, moduleProperty{Key: escapeProperty(key, true), Value: escapeProperty(values[key], false)})
+	}
+	return properties, nil
+}
+
+// Function to parse a pattern=replacement rename rule. The replacement may
+// reference capture groups of the pattern as $1, ${name}, etc.
+func parseRename(spec string) (*regexp.Regexp, string, error) {
+	pattern, replacement, ok := strings.Cut(spec, "=")
+	if !ok || pattern == "" {
+		return nil, "", fmt.Errorf("%q is not in pattern=replacement form", spec)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, "", err
+	}
+	return re, replacement, nil
+}
+
+// Characters allowed in a module id, and those replaced when normalizing one
+var (
+	validModuleName        = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+	invalidModuleNameChars = regexp.MustCompile(`[^a-z0-9._-]+`)
+	repeatedHyphens        = regexp.MustCompile(`-{2,}`)
+)
+
+// Helper function to check a module id, which becomes a directory of the
+// alfresco/module paths: "." and ".." are made of allowed characters but would
+// point outside of the module directory
+func isValidModuleName(name string) bool {
+	return validModuleName.MatchString(name) && strings.Trim(name, ".") != ""
+}
+
+// Function to normalize a module name to Alfresco module id conventions:
+// lowercase, with runs of spaces and invalid characters replaced by a hyphen
+func normalizeModuleName(name string) string {
+	normalized := invalidModuleNameChars.ReplaceAllString(strings.ToLower(name), "-")
+	normalized = repeatedHyphens.ReplaceAllString(normalized, "-")
+	return strings.Trim(normalized, "-")
+}
+
+func cleanModuleName(filename string) string {
+	// Remove file extension
+	name := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+
+	// Regular expression to match version patterns:
+	// - Matches patterns like "-1.0.0", "-1.0", "-v1.0.0", "_1.0.0", "_v1.0.0"
+	// - Handles both hyphen and underscore separators
+	// - Handles optional 'v' prefix before version number
+	// - Requires a dotted version, so trailing digits that are part of the name
+	//   ("log4j2", "oauth2", "base64", "acme-2") are kept
+	versionRegex := regexp.MustCompile(`[-_]v?\d+(\.\d+)+(-SNAPSHOT)?$`)
+
+	// Maven classifier following a dotted version, e.g. "-1.0.0-jar-with-dependencies",
+	// "-1.0.0-sources" or "-1.0.0-tests". Without a version, e.g. "acme-sources",
+	// the name is kept as it is.
+	classifierRegex := regexp.MustCompile(`([-_]v?\d+(\.\d+)+(-SNAPSHOT)?)-(jar-with-dependencies|[A-Za-z][A-Za-z0-9]*)$`)
+
+	// Remove the classifier, then version information
+	cleanName := classifierRegex.ReplaceAllString(name, "$1")
+	cleanName = versionRegex.ReplaceAllString(cleanName, "")
+
+	return cleanName
+}
+
+// Helper function to identify directory entries in the ZIP
+func isDirEntry(file *zip.File) bool {
+	return strings.HasSuffix(file.Name, "/") || file.FileInfo().IsDir()
+}
+
+// Function to check whether the content of the entry named name is an Alfresco
+// model, reading no further than its root element
+func isAlfrescoModel(logs logger, name string, content io.Reader) (bool, error) {
+	// Scan the tokens up to the root element, whatever comments or prolog precede it
+	reader := bufio.NewReader(content)
+	if prefix, err := reader.Peek(len(utf8BOM)); err == nil && string(prefix) == utf8BOM {
+		reader.Discard(len(utf8BOM))
+	}
+	decoder := newModelDecoder(reader)
+	// Only element names matter here, so declared encodings are read as is
+	decoder.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) { return input, nil }
+	for {
+		token, err := decoder.RawToken()
+		var syntaxErr *xml.SyntaxError
+		if err == io.EOF {
+			logs.debugf("Skipping %s, no root element", name)
+			return false, nil
+		}
+		if errors.As(err, &syntaxErr) {
+			logs.debugf("Skipping %s, not well-formed before the root element: %v", name, err)
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			switch {
+			case start.Name.Local != "model":
+				logs.debugf("Skipping %s, root element is <%s> instead of <model>", name, start.Name.Local)
+				return false, nil
+			case !hasAttr(start, "name"):
+				logs.debugf("Skipping %s, <model> has no name attribute", name)
+				return false, nil
+			}
+			return true, nil
+		}
+	}
+}
+
+// Helper function to check whether an element has an attribute with the given local name
+func hasAttr(start xml.StartElement, name string) bool {
+	for _, attr := range start.Attr {
+		if attr.Name.Local == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Byte order mark some editors write at the start of UTF-8 files
+const utf8BOM = "\xef\xbb\xbf"
+
+// Function to parse a model XML file into the Model structure
+func parseModel(path string) (*Model, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseModelContent(content)
+}
+
+// Function to parse model XML content into the Model structure
+func parseModelContent(content []byte) (*Model, error) {
+	if err := checkDoctype(content); err != nil {
+		return nil, err
+	}
+	var model Model
+	if err := newModelDecoder(bytes.NewReader(content)).Decode(&model); err != nil {
+		return nil, err
+	}
+	return &model, nil
+}
+
+// Helper function to collect the models that could be parsed
+func parsedModels(files []extractedFile) []*Model {
+	models := make([]*Model, 0, len(files))
+	for _, file := range files {
+		if file.Model != nil {
+			models = append(models, file.Model)
+		}
+	}
+	return models
+}
+
+// Dictionary namespace of the root element, e.g. http://www.alfresco.org/model/dictionary/1.0
+var dictionaryNamespaceRegex = regexp.MustCompile(`^http://www\.alfresco\.org/model/dictionary/([^/]+)$`)
+
+// Function to get the dictionary version a model is authored against from its root namespace
+func dictionaryVersion(model *Model) string {
+	if match := dictionaryNamespaceRegex.FindStringSubmatch(model.XMLName.Space); match != nil {
+		return match[1]
+	}
+	return ""
+}
+
+// Helper function to describe the dictionary version of an extracted model
+func fileDictionaryVersion(file extractedFile) string {
+	if file.Model == nil {
+		return "unknown"
+	}
+	if version := dictionaryVersion(file.Model); version != "" {
+		return version
+	}
+	return "unknown"
+}
+
+// Helper function to get the declared name of a model, or "unknown" when it could not be parsed
+func modelName(file extractedFile) string {
+	if file.Model == nil || file.Model.Name == "" {
+		return "unknown"
+	}
+	return file.Model.Name
+}
+
+// Helper function to describe where a model comes from, e.g. addon.jar!/model.xml
+func modelSource(file extractedFile) string {
+	return fmt.Sprintf("%s!/%s", file.Archive, file.Entry)
+}
+
+// Function to drop models declaring a name already declared by an earlier model,
+// and to give distinct models sharing an output path a numeric suffix, the
+// second model.xml becoming model-2.xml. When rename is false, an error listing
+// the colliding sources is returned instead.
+func dedupeModels(logs logger, files []extractedFile, rename bool) ([]extractedFile, error) {
+	declaredBy := make(map[string]extractedFile)
+	kept := make([]extractedFile, 0, len(files))
+	for _, file := range files {
+		if file.Model != nil && file.Model.Name != "" {
+			if first, exists := declaredBy[file.Model.Name]; exists {
+				logs.warnf("Skipping %s, model %s is already provided by %s", modelSource(file), file.Model.Name, modelSource(first))
+				continue
+			}
+			declaredBy[file.Model.Name] = file
+		}
+		kept = append(kept, file)
+	}
+
+	taken := make(map[string]bool)
+	for i, file := range kept {
+		if !taken[file.Target] {
+			taken[file.Target] = true
+			continue
+		}
+		if !rename {
+			return nil, fmt.Errorf("models %s share the output path %s", strings.Join(collidingSources(kept, file.Target), ", "), file.Target)
+		}
+		ext := path.Ext(file.Target)
+		base := strings.TrimSuffix(file.Target, ext)
+		target := file.Target
+		for n := 2; taken[target]; n++ {
+			target = fmt.Sprintf("%s-%d%s", base, n, ext)
+		}
+		logs.warnf("%s shares its output path %s with another model, packaging it as %s", modelSource(file), file.Target, target)
+		kept[i].Target = target
+		taken[target] = true
+	}
+	return kept, nil
+}
+
+// Helper function to list the sources of the models written to target
+func collidingSources(files []extractedFile, target string) []string {
+	var sources []string
+	for _, file := range files {
+		if file.Target == target {
+			sources = append(sources, modelSource(file))
+		}
+	}
+	return sources
+}
+
+// Function to check, before anything is written, that no two models are written
+// to the same entry, which would clobber the first one
+func checkModelPaths(moduleDir, modelDir string, files []extractedFile) error {
+	sources := make(map[string]extractedFile, len(files))
+	for _, file := range files {
+		modelPath := modelEntryPath(moduleDir, modelDir, file)
+		if first, taken := sources[modelPath]; taken {
+			return fmt.Errorf("models %s and %s would both be written to %s", modelSource(first), modelSource(file), modelPath)
+		}
+		sources[modelPath] = file
+	}
+	return nil
+}
+
+// Function to warn when the models of a bundle use different dictionary versions
+func checkDictionaryVersions(logs logger, files []extractedFile) {
+	byVersion := make(map[string][]string)
+	for _, file := range files {
+		if file.Model == nil {
+			continue
+		}
+		version := fileDictionaryVersion(file)
+		byVersion[version] = append(byVersion[version], file.Entry)
+	}
+	if len(byVersion) < 2 {
+		return
+	}
+
+	versions := make([]string, 0, len(byVersion))
+	for version := range byVersion {
+		versions = append(versions, version)
+	}
+	sort.Strings(versions)
+	logs.warnf("Models use different dictionary versions:")
+	for _, version := range versions {
+		logs.logf(levelWarn, "  %s: %s", version, strings.Join(byVersion[version], ", "))
+	}
+}
+
+// BPMN workflow definitions are identified by the Activiti/Flowable naming convention
+func isWorkflowDefinition(file *zip.File) bool {
+	return strings.HasSuffix(strings.ToLower(file.Name), ".bpmn20.xml")
+}
+
+// Function to resolve the path an archive entry is extracted to, rejecting
+// names that would escape dir (Zip Slip), such as ../../etc/passwd
+func extractionPath(dir, name string) (string, error) {
+	destPath := filepath.Join(dir, name)
+	rel, err := filepath.Rel(dir, destPath)
+	if err != nil {
+		return "", err
+	}
+	if rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || filepath.IsAbs(rel) {
+		return "", fmt.Errorf("path %s escapes the extraction directory", name)
+	}
+	return destPath, nil
+}
+
+// Helper function to read the content of an extracted file, kept in memory
+// instead of in the temp directory with -dry-run
+func readExtractedFile(file extractedFile) ([]byte, error) {
+	if file.Path == "" {
+		return file.Content, nil
+	}
+	return os.ReadFile(file.Path)
+}
+
+// Helper function to store content derived from the extracted files, e.g. a
+// merged model, as the file name of dir, or in memory when dir is empty
+func storeExtractedFile(file *extractedFile, dir, name string, content []byte) error {
+	if dir == "" {
+		file.Content = content
+		return nil
+	}
+	file.Path = filepath.Join(dir, name)
+	return os.WriteFile(file.Path, content, 0644)
+}
+
+// Helper function to read a whole archive entry in memory
+func readZipEntry(file *zip.File) ([]byte, error) {
+	rc, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+func extractFile(file *zip.File, destPath string) error {
+	rc, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	dest, err := os.Create(destPath)
+	if err != nil {
+		return err
+	}
+	defer dest.Close()
+
+	_, err = io.Copy(dest, rc)
+	return err
+}
+
+// Helper function to create a directory entry in the ZIP
+func createDirInZip(zipWriter *zip.Writer, name string, modified time.Time) error {
+	if !strings.HasSuffix(name, "/") {
+		name = name + "/"
+	}
+	header := &zip.FileHeader{
+		Name:     name,
+		Method:   zip.Store, // Directories should use STORE method
+		Modified: modified,
+	}
+	header.SetMode(0755 | os.ModeDir)
+	_, err := zipWriter.CreateHeader(header)
+	return err
+}
+
+// Helper function to create a file in the ZIP with current timestamp
+func createFileInZip(zipWriter *zip.Writer, name string, compress bool, modified time.Time) (io.Writer, error) {
+	header := &zip.FileHeader{
+		Name:     name,
+		Modified: modified,
+	}
+	if compress {
+		header.Method = zip.Deflate
+	} else {
+		header.Method = zip.Store
+	}
+	header.SetMode(0644)
+	return zipWriter.CreateHeader(header)
+}
+
+// Function to get the modification time of the archive entries: the -source-date
+// value, else the SOURCE_DATE_EPOCH environment variable, else the current time
+func sourceDate(value, epoch string) (time.Time, error) {
+	if value == "" {
+		if epoch == "" {
+			return time.Now(), nil
+		}
+		value = epoch
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0).UTC(), nil
+	}
+	date, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is neither an RFC3339 date nor a unix epoch", value)
+	}
+	return date.UTC(), nil
+}
+
+// Classpath directories holding the modules of the repository and of Share
+const (
+	repoTierDir  = "alfresco/module"
+	shareTierDir = "alfresco/web-extension"
+)
+
+// Helper function to build the classpath directory holding the module resources:
+// alfresco/module/<name> for the repository tier and alfresco/web-extension/<name>
+// for the Share tier. module.properties stays under alfresco/module/<name> for both.
+func moduleResourceDir(tier, moduleName string) string {
+	if tier == "share" {
+		return shareTierDir + "/" + moduleName
+	}
+	return repoTierDir + "/" + moduleName
+}
+
+// Helper function to build the JAR entry path of a packaged model
+func modelEntryPath(moduleDir, modelDir string, file extractedFile) string {
+	modelPath := fmt.Sprintf("%s/%s/%s", moduleDir, modelDir, file.Target)
+	// Ensure forward slashes
+	return strings.ReplaceAll(modelPath, "\\", "/")
+}
+
+// Helper function to normalize a comma-separated list of module aliases
+func cleanAliases(list string) string {
+	var aliases []string
+	for _, alias := range strings.Split(list, ",") {
+		if alias = strings.TrimSpace(alias); alias != "" {
+			aliases = append(aliases, alias)
+		}
+	}
+	return strings.Join(aliases, ",")
+}
+
+// Function to derive a module id from the name of the first parsed model,
+// e.g. acme:contentModel becomes acme-contentModel
+func modelModuleID(files []extractedFile) string {
+	for _, file := range files {
+		if file.Model != nil && file.Model.Name != "" {
+			return strings.ReplaceAll(file.Model.Name, ":", "-")
+		}
+	}
+	return ""
+}
+
+// Function to validate the model directory, which may span several segments
+// (e.g. model/custom) but must stay inside the module directory
+func cleanModelDir(dir string) (string, error) {
+	cleaned := path.Clean(strings.ReplaceAll(dir, "\\", "/"))
+	if cleaned == "." || path.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("model directory %q must be a relative path inside the module directory", dir)
+	}
+	return cleaned, nil
+}
+
+// Everything needed to lay out the generated module
+type moduleLayout struct {
+	Name      string
+	ID        string // module.id, defaults to Name
+	Tier      string // repo or share, see moduleResourceDir
+	Amp       bool   // Written as an AMP, which needs a module.properties whatever the tier
+	ModelDir  string
+	Version   string
+	BuiltBy   string // Built-By of the manifest
+	Tool      string // Version of the extractor recorded in the manifest
+	Models    []extractedFile
+	Workflows []extractedFile
+	Messages  []extractedFile
+	Templates *moduleTemplates
+	// Optional module.installState and module.aliases, left out when empty
+	InstallState string
+	Aliases      string
+	// module.title and module.description, defaulting to Name, and extra entries sorted by key
+	Title       string
+	Description string
+	Properties  []moduleProperty
+	// Additional MANIFEST.MF headers, wrapped with the others by wrapManifest
+	ManifestEntries []string
+	// Write META-INF/model-sources.properties mapping packaged files to their source entries
+	SourceIndex  bool
+	GeneratedDir string    // Directory receiving a copy of the rendered templates, if any
+	Modified     time.Time // Modification time of every archive entry
+	// Flate level of compressed ZIP entries, 1 (fastest) to 9 (smallest), 0 for
+	// the default level; StoreAll writes every entry uncompressed instead
+	CompressionLevel int
+	StoreAll         bool
+}
+
+// Destination the module layout is written to
+type moduleArchive interface {
+	createDir(name string) error
+	createFile(name string, content []byte, compress bool) error
+	// copyFile streams size bytes of src into a new file entry
+	copyFile(name string, src io.Reader, size int64, compress bool) error
+}
+
+// ZIP (JAR) implementation of moduleArchive
+type zipArchive struct {
+	zipWriter *zip.Writer
+	modified  time.Time
+	storeAll  bool // Store every entry uncompressed, whatever the caller asks
+}
+
+// Function to create the ZIP implementation of moduleArchive, registering a
+// compressor with the flate level of the layout when it is not the default one
+func newZipArchive(zipWriter *zip.Writer, layout moduleLayout) zipArchive {
+	if level := layout.CompressionLevel; level != 0 && !layout.StoreAll {
+		zipWriter.RegisterCompressor(zip.Deflate, func(out io.Writer) (io.WriteCloser, error) {
+			return flate.NewWriter(out, level)
+		})
+	}
+	return zipArchive{zipWriter, layout.Modified, layout.StoreAll}
+}
+
+func (a zipArchive) createDir(name string) error {
+	return createDirInZip(a.zipWriter, name, a.modified)
+}
+
+func (a zipArchive) createFile(name string, content []byte, compress bool) error {
+	return a.copyFile(name, bytes.NewReader(content), int64(len(content)), compress)
+}
+
+func (a zipArchive) copyFile(name string, src io.Reader, size int64, compress bool) error {
+	writer, err := createFileInZip(a.zipWriter, name, compress && !a.storeAll, a.modified)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(writer, src)
+	return err
+}
+
+func createModuleJar(jarPath string, layout moduleLayout) error {
+	jarFile, err := os.Create(jarPath)
+	if err != nil {
+		return err
+	}
+	defer jarFile.Close()
+
+	zipWriter := zip.NewWriter(jarFile)
+	defer zipWriter.Close()
+
+	return writeModule(newZipArchive(zipWriter, layout), layout, true)
+}
+
+// Function to write the module structure (directories, generated files, models
+// and workflows) to an archive, including the META-INF entries when requested
+func writeModule(archive moduleArchive, layout moduleLayout, withMetaInf bool) error {
+	moduleName, modelDir := layout.Name, layout.ModelDir
+	moduleDir := moduleResourceDir(layout.Tier, moduleName)
+	files, workflows := layout.Models, layout.Workflows
+	if err := checkModelPaths(moduleDir, modelDir, files); err != nil {
+		return err
+	}
+
+	// A Share module is declared by its extension module, so only an AMP, which
+	// the Module Management Tool identifies by it, keeps a module.properties
+	withProperties := layout.Tier != "share" || layout.Amp
+
+	// Create all necessary directories first
+	directories := []string{
+		fmt.Sprintf("alfresco/"),
+	}
+	if withProperties {
+		directories = append(directories, "alfresco/module/", fmt.Sprintf("alfresco/module/%s/", moduleName))
+	}
+	if layout.Tier == "share" {
+		directories = append(directories, "alfresco/site-data/", shareExtensionsDir+"/")
+	}
+	if withMetaInf {
+		directories = append(directories, "META-INF/")
+	}
+	if len(workflows) > 0 {
+		directories = append(directories, fmt.Sprintf("%s/workflow/", moduleDir))
+	}
+	if len(layout.Messages) > 0 {
+		directories = append(directories, fmt.Sprintf("%s/messages/", moduleDir))
+	}
+
+	// Include every intermediate directory of the model directory and of models kept in subdirectories
+	seen := make(map[string]bool)
+	for _, dir := range directories {
+		seen[dir] = true
+	}
+	addParentDirs := func(dir string) {
+		for ; !seen[dir+"/"]; dir = path.Dir(dir) {
+			seen[dir+"/"] = true
+			directories = append(directories, dir+"/")
+		}
+	}
+	addParentDirs(fmt.Sprintf("%s/%s", moduleDir, modelDir))
+	for _, file := range files {
+		addParentDirs(path.Dir(modelEntryPath(moduleDir, modelDir, file)))
+	}
+
+	// Sort directories to ensure parent directories are created first
+	sort.Strings(directories)
+	for _, dir := range directories {
+		if err := archive.createDir(dir); err != nil {
+			return fmt.Errorf("failed to create directory %s: %v", dir, err)
+		}
+	}
+
+	// Prepare model paths for module-context.xml in load order, imported models
+	// before the models importing them
+	ordered, err := modelLoadOrder(files)
+	if err != nil {
+		return fmt.Errorf("failed to order models: %v", err)
+	}
+	var modelPaths []string
+	for _, file := range ordered {
+		modelPaths = append(modelPaths, modelEntryPath(moduleDir, modelDir, file))
+	}
+
+	// Prepare workflow paths for the workflowDeployer bean
+	var workflowPaths []string
+	for _, file := range workflows {
+		workflowPaths = append(workflowPaths, workflowEntryPath(moduleDir, file))
+	}
+	sort.Strings(workflowPaths)
+
+	// Prepare module data for templates with version
+	moduleID := layout.ID
+	if moduleID == "" {
+		moduleID = moduleName
+	}
+	title, description := layout.Title, layout.Description
+	if title == "" {
+		title = moduleName
+	}
+	if description == "" {
+		description = moduleName
+	}
+	moduleData := ModuleData{
+		Name:            moduleName,
+		ID:              moduleID,
+		Title:           escapeProperty(title, false),
+		Description:     escapeProperty(description, false),
+		Properties:      layout.Properties,
+		ManifestEntries: layout.ManifestEntries,
+		InstallState:    layout.InstallState,
+		Aliases:         layout.Aliases,
+		Version:         layout.Version,
+		BuiltBy:         layout.BuiltBy,
+		BuildJdk:        runtime.Version(),
+		ToolVersion:     layout.Tool,
+		ModelPaths:      modelPaths,
+		WorkflowPaths:   workflowPaths,
+		MessageBundles:  messageBundleNames(moduleDir, layout.Messages),
+		ContextPath:     moduleDir + "/module-context.xml",
+	}
+
+	// Rendered templates, also written to layout.GeneratedDir when set
+	var generated []generatedFile
+
+	// Create META-INF/MANIFEST.MF
+	if withMetaInf {
+		manifest, err := renderTemplate(layout.Templates.manifest, moduleData)
+		if err != nil {
+			return err
+		}
+		manifest = wrapManifest(manifest)
+		if err := archive.createFile("META-INF/MANIFEST.MF", manifest, false); err != nil {
+			return err
+		}
+		generated = append(generated, generatedFile{"MANIFEST.MF", manifest})
+	}
+
+	// Create META-INF/model-sources.properties
+	if withMetaInf && layout.SourceIndex {
+		if err := archive.createFile("META-INF/model-sources.properties", sourceIndex(layout), true); err != nil {
+			return err
+		}
+	}
+
+	// Create module.properties
+	if withProperties {
+		props, err := renderTemplate(layout.Templates.properties, moduleData)
+		if err != nil {
+			return err
+		}
+		if err := archive.createFile(fmt.Sprintf("alfresco/module/%s/module.properties", moduleName), props, true); err != nil {
+			return err
+		}
+		generated = append(generated, generatedFile{"module.properties", props})
+	}
+
+	// Create the extension module of a Share module
+	if layout.Tier == "share" {
+		extension, err := renderTemplate(layout.Templates.shareExtension, moduleData)
+		if err != nil {
+			return err
+		}
+		if err := archive.createFile(shareExtensionPath(moduleName), extension, true); err != nil {
+			return err
+		}
+		generated = append(generated, generatedFile{path.Base(shareExtensionPath(moduleName)), extension})
+	}
+
+	// Create module-context.xml
+	context, err := renderTemplate(layout.Templates.context, moduleData)
+	if err != nil {
+		return err
+	}
+	if err := archive.createFile(moduleData.ContextPath, context, true); err != nil {
+		return err
+	}
+	generated = append(generated, generatedFile{"module-context.xml", context})
+
+	// Share only loads the *-context.xml files of alfresco/web-extension, so a
+	// Share module gets a context file there importing its module-context.xml
+	if layout.Tier == "share" {
+		wrapper, err := renderTemplate(layout.Templates.shareImport, moduleData)
+		if err != nil {
+			return err
+		}
+		if err := archive.createFile(shareImportPath(moduleName), wrapper, true); err != nil {
+			return err
+		}
+		generated = append(generated, generatedFile{path.Base(shareImportPath(moduleName)), wrapper})
+	}
+
+	// Keep a copy of the rendered templates outside the JAR
+	if withMetaInf && layout.GeneratedDir != "" {
+		if err := writeGeneratedFiles(layout.GeneratedDir, generated); err != nil {
+			return err
+		}
+	}
+
+	// Add XML files to JAR in the module's model directory
+	if err := addFilesToArchive(archive, fmt.Sprintf("%s/%s", moduleDir, modelDir), files); err != nil {
+		return err
+	}
+
+	// Add workflow definitions to JAR in the module's workflow directory
+	if err := addFilesToArchive(archive, fmt.Sprintf("%s/workflow", moduleDir), workflows); err != nil {
+		return err
+	}
+
+	// Add message bundles to JAR in the module's messages directory
+	return addFilesToArchive(archive, fmt.Sprintf("%s/messages", moduleDir), layout.Messages)
+}
+
+// Helper function to build the JAR entry path of a packaged workflow definition
+func workflowEntryPath(moduleDir string, file extractedFile) string {
+	return fmt.Sprintf("%s/workflow/%s", moduleDir, file.Target)
+}
+
+// Helper function to build the entry path of the context file Share loads for a module
+func shareImportPath(moduleName string) string {
+	return fmt.Sprintf("%s/%s-context.xml", shareTierDir, moduleName)
+}
+
+// Rendered template written to the -emit-generated directory
+type generatedFile struct {
+	Name    string
+	Content []byte
+}
+
+// Function to write the rendered templates to a directory outside the JAR
+func writeGeneratedFiles(dir string, files []generatedFile) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %v", dir, err)
+	}
+	for _, file := range files {
+		if err := os.WriteFile(filepath.Join(dir, file.Name), file.Content, 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %v", file.Name, err)
+		}
+	}
+	return nil
+}
+
+// Function to render the source index, mapping each packaged model and
+// workflow path to the archive entry it was extracted from
+func sourceIndex(layout moduleLayout) []byte {
+	var buffer bytes.Buffer
+	buffer.WriteString("# Source archive entry of each packaged file\n")
+	moduleDir := moduleResourceDir(layout.Tier, layout.Name)
+	for _, file := range layout.Models {
+		fmt.Fprintf(&buffer, "%s=%s\n", escapeProperty(modelEntryPath(moduleDir, layout.ModelDir, file), true), escapeProperty(file.Entry, false))
+	}
+	for _, file := range layout.Workflows {
+		fmt.Fprintf(&buffer, "%s=%s\n", escapeProperty(workflowEntryPath(moduleDir, file), true), escapeProperty(file.Entry, false))
+	}
+	for _, file := range layout.Messages {
+		fmt.Fprintf(&buffer, "%s=%s\n", escapeProperty(messageEntryPath(moduleDir, file), true), escapeProperty(file.Entry, false))
+	}
+	return buffer.Bytes()
+}
+
+// Helper function to escape a key or value for a Java .properties file, the
+// control characters being written as in Properties.store
+func escapeProperty(value string, key bool) string {
+	var builder strings.Builder
+	for i, r := range value {
+		switch {
+		case r == '\\':
+			builder.WriteString("\\\\")
+		case r == '\t':
+			builder.WriteString("\\t")
+		case r == '\n':
+			builder.WriteString("\\n")
+		case r == '\r':
+			builder.WriteString("\\r")
+		case r == '\f':
+			builder.WriteString("\\f")
+		case key && strings.ContainsRune(" :=#!", r), !key && i == 0 && r == ' ':
+			builder.WriteRune('\\')
+			builder.WriteRune(r)
+		default:
+			builder.WriteRune(r)
+		}
+	}
+	return builder.String()
+}
+
+// Function to read the entries of a Java .properties file. Blank lines and
+// comments starting with # or ! are skipped, a key is separated from its value
+// by =, : or whitespace, both are trimmed, and a line ending with an odd number
+// of backslashes continues on the next one. A UTF-8 BOM and the carriage
+// returns of CRLF line endings, as written on Windows, are dropped.
+func readProperties(r io.Reader) (map[string]string, error) {
+	entries := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	var logical strings.Builder
+	continued := false
+	for first := true; scanner.Scan(); first = false {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if first {
+			line = strings.TrimPrefix(line, utf8BOM)
+		}
+		line = strings.TrimLeft(line, " \t\f")
+		if !continued && (line == "" || line[0] == '#' || line[0] == '!') {
+			continue
+		}
+		backslashes := len(line) - len(strings.TrimRight(line, "\\"))
+		continued = backslashes%2 == 1
+		if continued {
+			line = line[:len(line)-1]
+		}
+		logical.WriteString(line)
+		if !continued {
+			key, value := splitPropertyLine(logical.String())
+			entries[key] = value
+			logical.Reset()
+		}
+	}
+	if continued {
+		key, value := splitPropertyLine(logical.String())
+		entries[key] = value
+	}
+	return entries, scanner.Err()
+}
+
+// Helper function to split a logical .properties line at the first unescaped
+// separator, unescaping the key and the value
+func splitPropertyLine(line string) (string, string) {
+	end := len(line)
+	for i := 0; i < len(line); i++ {
+		if line[i] == '\\' {
+			i++
+			continue
+		}
+		if strings.IndexByte("=: \t\f", line[i]) >= 0 {
+			end = i
+			break
+		}
+	}
+	value := strings.TrimLeft(line[end:], " \t\f")
+	if value != "" && (value[0] == '=' || value[0] == ':') {
+		value = value[1:]
+	}
+	return unescapeProperty(line[:end]), unescapeProperty(strings.Trim(value, " \t\f"))
+}
+
+// Helper function to resolve the backslash escapes of a .properties key or value
+func unescapeProperty(value string) string {
+	if !strings.Contains(value, "\\") {
+		return value
+	}
+	var builder strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' || i == len(value)-1 {
+			builder.WriteByte(value[i])
+			continue
+		}
+		i++
+		switch value[i] {
+		case 't':
+			builder.WriteByte('\t')
+		case 'n':
+			builder.WriteByte('\n')
+		case 'r':
+			builder.WriteByte('\r')
+		case 'f':
+			builder.WriteByte('\f')
+		case 'u':
+			if code, err := strconv.ParseUint(value[i+1:min(i+5, len(value))], 16, 16); err == nil && i+5 <= len(value) {
+				builder.WriteRune(rune(code))
+				i += 4
+			} else {
+				builder.WriteByte('u')
+			}
+		default:
+			builder.WriteByte(value[i])
+		}
+	}
+	return builder.String()
+}
+
+// Helper function to copy local files into a directory of the archive
+func addFilesToArchive(archive moduleArchive, dir string, files []extractedFile) error {
+	for _, file := range files {
+		fileName := fmt.Sprintf("%s/%s", dir, file.Target)
+		// Ensure forward slashes
+		fileName = strings.ReplaceAll(fileName, "\\", "/")
+
+		if file.Path == "" {
+			if err := archive.copyFile(fileName, bytes.NewReader(file.Content), int64(len(file.Content)), true); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := copyFileToArchive(archive, fileName, file.Path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Helper function to stream a local file into the archive without loading it in memory
+func copyFileToArchive(archive moduleArchive, name, localPath string) error {
+	src, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	return archive.copyFile(name, src, info.Size(), true)
+}
@@ -0,0 +1,323 @@
+package extractor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Namespace declaration found across the models of an archive
+type namespaceUsage struct {
+	Prefix   string   `json:"prefix"`
+	URI      string   `json:"uri"`
+	Models   []string `json:"models"`
+	Conflict bool     `json:"conflict"`
+}
+
+// Function to collect the deduplicated prefix/URI pairs declared by the models,
+// flagging prefixes declared with more than one URI
+func collectNamespaces(files []extractedFile) []namespaceUsage {
+	byPair := make(map[Namespace]*namespaceUsage)
+	urisByPrefix := make(map[string]map[string]bool)
+	for _, file := range files {
+		if file.Model == nil {
+			continue
+		}
+		for _, namespace := range file.Model.Namespaces {
+			usage, ok := byPair[namespace]
+			if !ok {
+				usage = &namespaceUsage{Prefix: namespace.Prefix, URI: namespace.URI}
+				byPair[namespace] = usage
+			}
+			usage.Models = append(usage.Models, file.Model.Name)
+			if urisByPrefix[namespace.Prefix] == nil {
+				urisByPrefix[namespace.Prefix] = make(map[string]bool)
+			}
+			urisByPrefix[namespace.Prefix][namespace.URI] = true
+		}
+	}
+
+	usages := make([]namespaceUsage, 0, len(byPair))
+	for _, usage := range byPair {
+		usage.Conflict = len(urisByPrefix[usage.Prefix]) > 1
+		usages = append(usages, *usage)
+	}
+	sort.Slice(usages, func(i, j int) bool {
+		if usages[i].Prefix != usages[j].Prefix {
+			return usages[i].Prefix < usages[j].Prefix
+		}
+		return usages[i].URI < usages[j].URI
+	})
+	return usages
+}
+
+// Function to print the namespaces as prefix=uri lines or as JSON. Prefixes
+// declared with several URIs are reported as warnings.
+func printNamespaces(logs logger, w io.Writer, usages []namespaceUsage, format string) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(usages); err != nil {
+			return err
+		}
+	case "text":
+		for _, usage := range usages {
+			fmt.Fprintf(w, "%s=%s\n", usage.Prefix, usage.URI)
+		}
+	default:
+		return fmt.Errorf("unknown format %q: use text or json", format)
+	}
+
+	conflicts := make(map[string][]string)
+	for _, usage := range usages {
+		if usage.Conflict {
+			conflicts[usage.Prefix] = append(conflicts[usage.Prefix], usage.URI)
+		}
+	}
+	for _, prefix := range conflictingPrefixes(usages) {
+		logs.warnf("Prefix %s is declared with multiple URIs: %s", prefix, strings.Join(conflicts[prefix], ", "))
+	}
+	return nil
+}
+
+// Helper function to list, sorted, the prefixes declared with more than one URI
+func conflictingPrefixes(usages []namespaceUsage) []string {
+	var prefixes []string
+	for _, usage := range usages {
+		if usage.Conflict && !slices.Contains(prefixes, usage.Prefix) {
+			prefixes = append(prefixes, usage.Prefix)
+		}
+	}
+	return prefixes
+}
+
+// Function to find the namespace prefixes declared with different URIs, returning
+// one message per prefix naming the first two models that disagree on it
+func findPrefixConflicts(files []extractedFile) []string {
+	type declaration struct {
+		uri  string
+		file extractedFile
+	}
+	first := make(map[string]declaration)
+	var conflicts []string
+	reported := make(map[string]bool)
+	for _, file := range files {
+		if file.Model == nil {
+			continue
+		}
+		for _, namespace := range file.Model.Namespaces {
+			declared, ok := first[namespace.Prefix]
+			if !ok {
+				first[namespace.Prefix] = declaration{namespace.URI, file}
+				continue
+			}
+			if declared.uri != namespace.URI && !reported[namespace.Prefix] {
+				reported[namespace.Prefix] = true
+				conflicts = append(conflicts, fmt.Sprintf("prefix %s is declared as %s by %s and as %s by %s",
+					namespace.Prefix, declared.uri, modelSource(declared.file), namespace.URI, modelSource(file)))
+			}
+		}
+	}
+	return conflicts
+}
+
+// Function to find the models declaring any of the forbidden namespace URIs,
+// returning one message per offending declaration
+func findForbiddenNamespaces(files []extractedFile, forbidden []string) []string {
+	forbiddenURIs := make(map[string]bool, len(forbidden))
+	for _, uri := range forbidden {
+		forbiddenURIs[strings.TrimSpace(uri)] = true
+	}
+
+	var offenders []string
+	for _, file := range files {
+		if file.Model == nil {
+			continue
+		}
+		for _, namespace := range file.Model.Namespaces {
+			if forbiddenURIs[namespace.URI] {
+				offenders = append(offenders, fmt.Sprintf("%s (%s) declares forbidden namespace %s", file.Model.Name, file.Entry, namespace.URI))
+			}
+		}
+	}
+	return offenders
+}
+
+// Function to keep the models declaring a namespace prefix of include, when
+// any is given, and none of exclude. Models that couldn't be parsed have no
+// known prefixes, so they are only kept without include prefixes. It returns
+// the kept models and the number filtered out.
+func filterByPrefix(logs logger, files []extractedFile, include, exclude []string) ([]extractedFile, int) {
+	if len(include) == 0 && len(exclude) == 0 {
+		return files, 0
+	}
+	kept := make([]extractedFile, 0, len(files))
+	for _, file := range files {
+		var prefixes []string
+		if file.Model != nil {
+			for _, namespace := range file.Model.Namespaces {
+				prefixes = append(prefixes, namespace.Prefix)
+			}
+		}
+		included := len(include) == 0 || slices.ContainsFunc(prefixes, func(prefix string) bool { return slices.Contains(include, prefix) })
+		excluded := slices.ContainsFunc(prefixes, func(prefix string) bool { return slices.Contains(exclude, prefix) })
+		if !included || excluded {
+			logs.debugf("Filtering out %s, declaring prefixes %s", file.Entry, strings.Join(prefixes, ", "))
+			continue
+		}
+		kept = append(kept, file)
+	}
+	return kept, len(files) - len(kept)
+}
+
+// Namespace URIs conventionally end with a version segment, e.g. http://www.acme.org/model/content/1.0
+var versionedURIRegex = regexp.MustCompile(`^(.+)/(\d+(?:\.\d+)*)$`)
+
+// Function to warn when a model imports a namespace in a version other than the
+// one the bundle provides, e.g. importing .../acme/1.0 while only .../acme/2.0 is bundled
+func checkImportVersions(logs logger, files []extractedFile) {
+	// Namespaces declared in the bundle, and their versions by unversioned URI
+	declared := make(map[string]bool)
+	bundled := make(map[string][]string)
+	for _, file := range files {
+		if file.Model == nil {
+			continue
+		}
+		for _, namespace := range file.Model.Namespaces {
+			declared[namespace.URI] = true
+			if match := versionedURIRegex.FindStringSubmatch(namespace.URI); match != nil {
+				bundled[match[1]] = append(bundled[match[1]], match[2])
+			}
+		}
+	}
+
+	for _, file := range files {
+		if file.Model == nil {
+			continue
+		}
+		for _, imported := range file.Model.Imports {
+			if declared[imported.URI] {
+				continue
+			}
+			match := versionedURIRegex.FindStringSubmatch(imported.URI)
+			if match == nil || len(bundled[match[1]]) == 0 {
+				continue
+			}
+			logs.warnf("%s imports %s version %s, but the bundle provides version %s",
+				file.Model.Name, match[1], match[2], strings.Join(bundled[match[1]], ", "))
+		}
+	}
+}
+
+// Function to keep only the model named root and the models providing the
+// namespaces it imports, directly or transitively
+func importClosure(files []extractedFile, root string) ([]extractedFile, error) {
+	// Index the bundled models by name and by the namespaces they declare
+	byName := make(map[string]int)
+	byURI := make(map[string]int)
+	for i, file := range files {
+		if file.Model == nil {
+			continue
+		}
+		byName[file.Model.Name] = i
+		for _, namespace := range file.Model.Namespaces {
+			byURI[namespace.URI] = i
+		}
+	}
+	start, ok := byName[root]
+	if !ok {
+		return nil, fmt.Errorf("model %s not found in the archive", root)
+	}
+
+	included := map[int]bool{start: true}
+	pending := []int{start}
+	for len(pending) > 0 {
+		model := files[pending[0]].Model
+		pending = pending[1:]
+		for _, imported := range model.Imports {
+			if provider, bundled := byURI[imported.URI]; bundled && !included[provider] {
+				included[provider] = true
+				pending = append(pending, provider)
+			}
+		}
+	}
+
+	closure := make([]extractedFile, 0, len(included))
+	for i, file := range files {
+		if included[i] {
+			closure = append(closure, file)
+		}
+	}
+	return closure, nil
+}
+
+// Function to order the models so that every bundled model is listed before
+// the models importing its namespaces, as Alfresco bootstraps them in the
+// order of module-context.xml. Independent models keep their path order.
+func modelLoadOrder(files []extractedFile) ([]extractedFile, error) {
+	sorted := slices.Clone(files)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Target < sorted[j].Target })
+
+	// Index the bundled models by the namespaces they declare
+	byURI := make(map[string]int)
+	for i, file := range sorted {
+		if file.Model == nil {
+			continue
+		}
+		for _, namespace := range file.Model.Namespaces {
+			byURI[namespace.URI] = i
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]int, len(sorted))
+	ordered := make([]extractedFile, 0, len(sorted))
+	var path []int        // Models being visited, from the first one to the current one
+	var pathURIs []string // Namespace imported to reach each model of path after the first one
+	var visit func(i int) error
+	visit = func(i int) error {
+		state[i] = visiting
+		path = append(path, i)
+		if model := sorted[i].Model; model != nil {
+			for _, imported := range model.Imports {
+				provider, bundled := byURI[imported.URI]
+				if !bundled || provider == i {
+					continue
+				}
+				switch state[provider] {
+				case visiting:
+					start := slices.Index(path, provider)
+					cycle := append(slices.Clone(pathURIs[start:]), imported.URI)
+					return fmt.Errorf("import cycle between namespaces %s", strings.Join(cycle, ", "))
+				case unvisited:
+					pathURIs = append(pathURIs, imported.URI)
+					if err := visit(provider); err != nil {
+						return err
+					}
+					pathURIs = pathURIs[:len(pathURIs)-1]
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[i] = visited
+		ordered = append(ordered, sorted[i])
+		return nil
+	}
+	for i := range sorted {
+		if state[i] == unvisited {
+			if err := visit(i); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return ordered, nil
+}
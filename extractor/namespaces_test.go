@@ -0,0 +1,115 @@
+package extractor
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// Helper function to build an extracted model target declaring the namespace
+// prefix and importing the namespaces of the imports prefixes
+func testImportingModel(t testing.TB, target, prefix string, imports ...string) extractedFile {
+	t.Helper()
+	model, err := parseModelContent([]byte(testModelWithPrefix(prefix, imports...)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return extractedFile{Entry: target, Target: target, Model: model}
+}
+
+func TestModelLoadOrder(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []extractedFile
+		want  []string
+	}{
+		{
+			name: "independent models keep their path order",
+			files: []extractedFile{
+				testImportingModel(t, "c.xml", "c"),
+				testImportingModel(t, "a.xml", "a"),
+				testImportingModel(t, "b.xml", "b"),
+			},
+			want: []string{"a.xml", "b.xml", "c.xml"},
+		},
+		{
+			name: "imported model first",
+			files: []extractedFile{
+				testImportingModel(t, "a.xml", "a", "c"),
+				testImportingModel(t, "b.xml", "b"),
+				testImportingModel(t, "c.xml", "c"),
+			},
+			want: []string{"c.xml", "a.xml", "b.xml"},
+		},
+		{
+			name: "chain of imports",
+			files: []extractedFile{
+				testImportingModel(t, "a.xml", "a", "b"),
+				testImportingModel(t, "b.xml", "b", "c"),
+				testImportingModel(t, "c.xml", "c"),
+			},
+			want: []string{"c.xml", "b.xml", "a.xml"},
+		},
+		{
+			name: "namespaces not bundled are ignored",
+			files: []extractedFile{
+				testImportingModel(t, "a.xml", "a", "cm", "b"),
+				testImportingModel(t, "b.xml", "b", "cm"),
+			},
+			want: []string{"b.xml", "a.xml"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ordered, err := modelLoadOrder(tt.files)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, file := range ordered {
+				got = append(got, file.Target)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("modelLoadOrder() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestModelLoadOrderCycle(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []extractedFile
+		want  []string
+	}{
+		{
+			name: "two models",
+			files: []extractedFile{
+				testImportingModel(t, "a.xml", "a", "b"),
+				testImportingModel(t, "b.xml", "b", "a"),
+			},
+			want: []string{"b", "a"},
+		},
+		{
+			name: "three models",
+			files: []extractedFile{
+				testImportingModel(t, "a.xml", "a", "b"),
+				testImportingModel(t, "b.xml", "b", "c"),
+				testImportingModel(t, "c.xml", "c", "a"),
+			},
+			want: []string{"b", "c", "a"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uris := make([]string, 0, len(tt.want))
+			for _, prefix := range tt.want {
+				uris = append(uris, testNamespaceURI(prefix))
+			}
+			want := "import cycle between namespaces " + strings.Join(uris, ", ")
+			if _, err := modelLoadOrder(tt.files); err == nil || err.Error() != want {
+				t.Errorf("modelLoadOrder() error = %v, want %q", err, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package extractor
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strings"
+
+	"golang.org/x/text/encoding"
+)
+
+// Function to open the archives nested in an input archive, such as the AMP
+// of a distribution ZIP or the JARs in the lib directory of an AMP, down to
+// maxDepth levels. Nested archives are read in memory as zip.Reader requires
+// random access; those that cannot be opened are skipped with a warning, and
+// counted in the number of skipped archives returned.
+func openNestedArchives(logs logger, parent inputArchive, entryNameEncoding encoding.Encoding, maxDepth, depth int) ([]inputArchive, int) {
+	if depth > maxDepth {
+		return nil, 0
+	}
+
+	var nested []inputArchive
+	skipped := 0
+	for _, file := range parent.Reader.File {
+		if isDirEntry(file) || !slices.Contains(archiveExtensions, strings.ToLower(path.Ext(file.Name))) {
+			continue
+		}
+		// Only the classpath of a WAR is scanned, which leaves out WEB-INF/lib
+		if parent.IsWar && !strings.HasPrefix(file.Name, parent.TrimPrefix) {
+			continue
+		}
+
+		archivePath := fmt.Sprintf("%s!/%s", parent.Path, file.Name)
+		reader, err := openNestedReader(file)
+		if err != nil {
+			logs.warnf("Skipping nested archive %s: %v", archivePath, err)
+			skipped++
+			continue
+		}
+		archive, err := newInputArchive(logs, archivePath, reader, "", entryNameEncoding)
+		if err != nil {
+			logs.warnf("Skipping nested archive %s: %v", archivePath, err)
+			skipped++
+			continue
+		}
+		nested = append(nested, archive)
+		deeper, deeperSkipped := openNestedArchives(logs, archive, entryNameEncoding, maxDepth, depth+1)
+		nested = append(nested, deeper...)
+		skipped += deeperSkipped
+	}
+	return nested, skipped
+}
+
+// Helper function to read an archive entry in memory and open it as a ZIP
+func openNestedReader(file *zip.File) (*zip.Reader, error) {
+	rc, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	content, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, err
+	}
+	return zip.NewReader(bytes.NewReader(content), int64(len(content)))
+}
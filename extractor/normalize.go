@@ -0,0 +1,204 @@
+package extractor
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Node of a model document being normalized. Text keeps its source form, so
+// character references and CDATA sections are written back unchanged.
+type xmlNode struct {
+	start    *xml.StartElement // Element, or nil for text and other markup
+	markup   string            // Comment, processing instruction or directive, as written
+	text     string            // Character data, as written in the source
+	children []*xmlNode
+}
+
+// Function to normalize the models in place with -normalize. A model that
+// can't be normalized is kept as it is, with a warning.
+func normalizeModelFiles(logs logger, files []extractedFile) {
+	for i, file := range files {
+		content, err := readExtractedFile(file)
+		if err != nil {
+			logs.warnf("Could not normalize %s: %v", file.Entry, err)
+			continue
+		}
+		normalized, err := normalizeModelXML(content)
+		if err != nil {
+			logs.warnf("Could not normalize %s, keeping it as is: %v", file.Entry, err)
+			continue
+		}
+		if file.Path == "" {
+			files[i].Content = normalized
+			continue
+		}
+		if err := os.WriteFile(file.Path, normalized, 0644); err != nil {
+			logs.warnf("Could not normalize %s: %v", file.Entry, err)
+		}
+	}
+}
+
+// Encoding named by the XML declaration at the start of a document
+var xmlDeclarationEncodingRegex = regexp.MustCompile(`^\s*<\?xml[^>]*?\sencoding\s*=\s*["']([^"']+)["']`)
+
+// Function to re-serialize a model with a canonical XML declaration and a two
+// space indentation. A model declaring another encoding, e.g. ISO-8859-1, is
+// transcoded to UTF-8 to match the declaration. Attributes keep their order and
+// prefixes, and text, CDATA sections included, is copied verbatim; only the
+// whitespace between elements changes, elements with mixed content being
+// written as they are.
+func normalizeModelXML(content []byte) ([]byte, error) {
+	content = bytes.TrimPrefix(content, []byte(utf8BOM))
+	if match := xmlDeclarationEncodingRegex.FindSubmatch(content); match != nil {
+		label := string(match[1])
+		if !strings.EqualFold(label, "UTF-8") && !strings.EqualFold(label, "UTF8") {
+			enc, err := lookupEncoding(label)
+			if err != nil {
+				return nil, err
+			}
+			if content, err = enc.NewDecoder().Bytes(content); err != nil {
+				return nil, fmt.Errorf("failed to decode %s content: %v", label, err)
+			}
+		}
+	}
+	decoder := newModelDecoder(bytes.NewReader(content))
+	// The content is UTF-8 by now, whatever its declaration says
+	decoder.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) { return input, nil }
+	document := &xmlNode{}
+	stack := []*xmlNode{document}
+	offset := decoder.InputOffset()
+	for {
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		raw := string(content[offset:decoder.InputOffset()])
+		offset = decoder.InputOffset()
+
+		parent := stack[len(stack)-1]
+		switch t := token.(type) {
+		case xml.StartElement:
+			start := t.Copy()
+			node := &xmlNode{start: &start}
+			parent.children = append(parent.children, node)
+			stack = append(stack, node)
+		case xml.EndElement:
+			if len(stack) == 1 {
+				return nil, fmt.Errorf("unexpected end element %s", qualifiedName(t.Name))
+			}
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			parent.children = append(parent.children, &xmlNode{text: raw})
+		case xml.ProcInst:
+			// The declaration is replaced with the canonical one
+			if t.Target != "xml" {
+				parent.children = append(parent.children, &xmlNode{markup: raw})
+			}
+		case xml.Comment, xml.Directive:
+			parent.children = append(parent.children, &xmlNode{markup: raw})
+		}
+	}
+	if len(stack) != 1 {
+		return nil, fmt.Errorf("element %s is not closed", qualifiedName(stack[len(stack)-1].start.Name))
+	}
+
+	var buffer bytes.Buffer
+	buffer.WriteString("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	for _, child := range document.children {
+		writeXMLNode(&buffer, child, 0)
+	}
+	return buffer.Bytes(), nil
+}
+
+// Helper function to write a node and its children on their own indented lines.
+// An element holding only text, or text mixed with elements, is written on a
+// single line with its content as is.
+func writeXMLNode(buffer *bytes.Buffer, node *xmlNode, depth int) {
+	indent := strings.Repeat("  ", depth)
+	switch {
+	case node.markup != "":
+		buffer.WriteString(indent + node.markup + "\n")
+		return
+	case node.start == nil:
+		// Whitespace between elements, replaced by the indentation
+		return
+	}
+
+	buffer.WriteString(indent)
+	if node.hasText() {
+		writeXMLInline(buffer, node)
+		buffer.WriteString("\n")
+		return
+	}
+	writeStartTag(buffer, node.start)
+	if len(node.children) == 0 {
+		buffer.WriteString("/>\n")
+		return
+	}
+	buffer.WriteString(">\n")
+	for _, child := range node.children {
+		writeXMLNode(buffer, child, depth+1)
+	}
+	buffer.WriteString(indent + "</" + qualifiedName(node.start.Name) + ">\n")
+}
+
+// Helper function to check whether an element holds text, such as a title or
+// the whitespace of <title> </title>, and not just whitespace between elements
+func (node *xmlNode) hasText() bool {
+	elements := false
+	for _, child := range node.children {
+		switch {
+		case child.start != nil || child.markup != "":
+			elements = true
+		case strings.TrimSpace(child.text) != "":
+			return true
+		}
+	}
+	return !elements && len(node.children) > 0
+}
+
+// Helper function to write a node and its children exactly as they were read
+func writeXMLInline(buffer *bytes.Buffer, node *xmlNode) {
+	switch {
+	case node.markup != "":
+		buffer.WriteString(node.markup)
+		return
+	case node.start == nil:
+		buffer.WriteString(node.text)
+		return
+	}
+	writeStartTag(buffer, node.start)
+	if len(node.children) == 0 {
+		buffer.WriteString("/>")
+		return
+	}
+	buffer.WriteString(">")
+	for _, child := range node.children {
+		writeXMLInline(buffer, child)
+	}
+	buffer.WriteString("</" + qualifiedName(node.start.Name) + ">")
+}
+
+// Helper function to write a start tag without its closing bracket
+func writeStartTag(buffer *bytes.Buffer, start *xml.StartElement) {
+	buffer.WriteString("<" + qualifiedName(start.Name))
+	for _, attr := range start.Attr {
+		fmt.Fprintf(buffer, " %s=\"%s\"", qualifiedName(attr.Name), xmlEscape(attr.Value))
+	}
+}
+
+// Helper function to write a raw name with its prefix, e.g. xmlns:cm
+func qualifiedName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
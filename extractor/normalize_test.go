@@ -0,0 +1,86 @@
+package extractor
+
+import "testing"
+
+func TestNormalizeModelXML(t *testing.T) {
+	const declaration = `<?xml version="1.0" encoding="UTF-8"?>` + "\n"
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "indentation and declaration",
+			content: `<model name="acme:model"><types>` + "\n\t\t" + `<type name="acme:doc"/></types></model>`,
+			want:    declaration + "<model name=\"acme:model\">\n  <types>\n    <type name=\"acme:doc\"/>\n  </types>\n</model>\n",
+		},
+		{
+			name:    "CDATA section",
+			content: `<model name="acme:model"><description><![CDATA[Types <b>&</b> aspects]]></description></model>`,
+			want:    declaration + "<model name=\"acme:model\">\n  <description><![CDATA[Types <b>&</b> aspects]]></description>\n</model>\n",
+		},
+		{
+			name:    "character references",
+			content: `<model name="acme:model"><title>Caf&#233; &amp; more</title></model>`,
+			want:    declaration + "<model name=\"acme:model\">\n  <title>Caf&#233; &amp; more</title>\n</model>\n",
+		},
+		{
+			name:    "attribute quoting",
+			content: `<model name='acme:model' title='Say "hi" &amp; &lt;bye&gt;' xmlns:cm="http://www.alfresco.org/model/content/1.0"/>`,
+			want:    declaration + "<model name=\"acme:model\" title=\"Say &#34;hi&#34; &amp; &lt;bye&gt;\" xmlns:cm=\"http://www.alfresco.org/model/content/1.0\"/>\n",
+		},
+		{
+			name:    "comments",
+			content: "<!-- License header -->\n<model name=\"acme:model\">\n<!-- Types -->\n<types/>\n</model>",
+			want:    declaration + "<!-- License header -->\n<model name=\"acme:model\">\n  <!-- Types -->\n  <types/>\n</model>\n",
+		},
+		{
+			name:    "whitespace-only text",
+			content: `<model name="acme:model"><description>  </description></model>`,
+			want:    declaration + "<model name=\"acme:model\">\n  <description>  </description>\n</model>\n",
+		},
+		{
+			name: "mixed content",
+			content: `<model name="acme:model"><description> Types and <b>aspects</b> of
+  Acme </description></model>`,
+			want: declaration + "<model name=\"acme:model\">\n  <description> Types and <b>aspects</b> of\n  Acme </description>\n</model>\n",
+		},
+		{
+			name:    "declaration replaced",
+			content: "<?xml version='1.0' encoding='utf-8' standalone='yes'?>\n<model name=\"acme:model\"/>",
+			want:    declaration + "<model name=\"acme:model\"/>\n",
+		},
+		{
+			name:    "ISO-8859-1 transcoded",
+			content: "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?>\n<model name=\"acme:model\"><title>Caf\xe9</title></model>",
+			want:    declaration + "<model name=\"acme:model\">\n  <title>Café</title>\n</model>\n",
+		},
+		{
+			name:    "byte order mark",
+			content: utf8BOM + `<model name="acme:model"/>`,
+			want:    declaration + "<model name=\"acme:model\"/>\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normalizeModelXML([]byte(tt.content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("normalizeModelXML() =\n%s\nwant\n%s", got, tt.want)
+			}
+			// The normalized model must still be a well-formed model
+			if _, err := parseModelContent(got); err != nil {
+				t.Errorf("normalized model can't be parsed: %v", err)
+			}
+		})
+	}
+}
+
+func TestNormalizeModelXMLUnknownEncoding(t *testing.T) {
+	content := "<?xml version=\"1.0\" encoding=\"x-unknown\"?>\n<model name=\"acme:model\"/>"
+	if _, err := normalizeModelXML([]byte(content)); err == nil {
+		t.Error("normalizeModelXML accepted an unknown encoding, want an error")
+	}
+}
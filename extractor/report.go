@@ -0,0 +1,45 @@
+package extractor
+
+import (
+	"encoding/json"
+	"os"
+)
+
+// Packaged model described by the -report file
+type modelReport struct {
+	Archive           string      `json:"archive"`
+	Entry             string      `json:"entry"`
+	Name              string      `json:"name"`
+	DictionaryVersion string      `json:"dictionaryVersion"`
+	Namespaces        []Namespace `json:"namespaces"`
+	Path              string      `json:"path"`
+}
+
+// Function to write a JSON report describing every packaged model: where it
+// was found, what it declares, the dictionary version it is authored against
+// and the path it was written to in the module
+func writeReport(reportPath, classpathRoot, moduleDir, modelDir string, files []extractedFile) error {
+	reports := make([]modelReport, 0, len(files))
+	for _, file := range files {
+		report := modelReport{
+			Archive:           file.Archive,
+			Entry:             file.Entry,
+			DictionaryVersion: fileDictionaryVersion(file),
+			Namespaces:        []Namespace{},
+			Path:              classpathRoot + modelEntryPath(moduleDir, modelDir, file),
+		}
+		if file.Model != nil {
+			report.Name = file.Model.Name
+			if file.Model.Namespaces != nil {
+				report.Namespaces = file.Model.Namespaces
+			}
+		}
+		reports = append(reports, report)
+	}
+
+	content, err := json.MarshalIndent(reports, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(reportPath, append(content, '\n'), 0644)
+}
@@ -0,0 +1,88 @@
+package extractor
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// Directory from which Surf loads the extension modules of Share
+const shareExtensionsDir = "alfresco/site-data/extensions"
+
+// Extension module written for a Share module, declaring its id and version as
+// Share reads them, instead of the module.properties of the repository
+const shareExtensionModuleTmpl = `<?xml version='1.0' encoding='UTF-8'?>
+<extension>
+    <modules>
+        <module>
+            <id>{{html .ID}}</id>
+            <version>{{html .Version}}</version>
+            <auto-deploy>true</auto-deploy>
+        </module>
+    </modules>
+</extension>
+`
+
+// Surf extension module file, e.g. alfresco/site-data/extensions/extension-module.xml
+type shareExtension struct {
+	Modules []struct {
+		ID      string `xml:"id"`
+		Version string `xml:"version"`
+	} `xml:"modules>module"`
+}
+
+// Helper function to build the entry path of the extension module of a Share module
+func shareExtensionPath(moduleName string) string {
+	return fmt.Sprintf("%s/%s-extension-module.xml", shareExtensionsDir, moduleName)
+}
+
+// Function to read the module id and version of a Share module from its
+// extension module: an extension-module.xml, or any XML file of the Surf
+// extensions directory. It reports found as false when the archive has none.
+func readExtensionModule(logs logger, zipReader *zip.Reader, trimPrefix string) (module moduleProperties, found bool, err error) {
+	var candidates []*zip.File
+	for _, file := range zipReader.File {
+		name := trimEntryPrefix(file.Name, trimPrefix)
+		if isDirEntry(file) || !strings.HasSuffix(name, ".xml") {
+			continue
+		}
+		if path.Base(name) == "extension-module.xml" || path.Dir(name) == shareExtensionsDir {
+			candidates = append(candidates, file)
+		}
+	}
+	if len(candidates) == 0 {
+		return moduleProperties{}, false, nil
+	}
+	if len(candidates) > 1 {
+		logs.warnf("Several extension modules found, using %s", candidates[0].Name)
+	}
+
+	rc, err := candidates[0].Open()
+	if err != nil {
+		return moduleProperties{}, false, err
+	}
+	defer rc.Close()
+	content, err := io.ReadAll(rc)
+	if err != nil {
+		return moduleProperties{}, false, err
+	}
+	if err := checkDoctype(content); err != nil {
+		return moduleProperties{}, false, fmt.Errorf("failed to parse %s: %v", candidates[0].Name, err)
+	}
+	var extension shareExtension
+	if err := newModelDecoder(bytes.NewReader(content)).Decode(&extension); err != nil {
+		return moduleProperties{}, false, fmt.Errorf("failed to parse %s: %v", candidates[0].Name, err)
+	}
+	if len(extension.Modules) == 0 {
+		return moduleProperties{}, false, fmt.Errorf("%s declares no module", candidates[0].Name)
+	}
+	first := extension.Modules[0]
+	return moduleProperties{
+		ID:      strings.TrimSpace(first.ID),
+		Version: strings.TrimSpace(first.Version),
+		Source:  candidates[0].Name,
+	}, true, nil
+}
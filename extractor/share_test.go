@@ -0,0 +1,69 @@
+package extractor
+
+import (
+	"encoding/xml"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCreateShareModuleJar(t *testing.T) {
+	model := writeTestModelFile(t)
+	// A comment can't hold --, so the context must still be well-formed
+	model.Target = "acme--v2 & co.xml"
+	layout := testLayout(t, model)
+	templates, err := loadTemplates("", "", "share")
+	if err != nil {
+		t.Fatal(err)
+	}
+	layout.Tier, layout.Templates = "share", templates
+	jarPath := filepath.Join(t.TempDir(), "acme.jar")
+	if err := createModuleJar(jarPath, layout); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := testArchiveEntries(t, jarPath)
+	for _, want := range []string{
+		"META-INF/MANIFEST.MF",
+		"alfresco/site-data/extensions/acme-extension-module.xml",
+		"alfresco/web-extension/acme-context.xml",
+		"alfresco/web-extension/acme/module-context.xml",
+		"alfresco/web-extension/acme/model/acme--v2 & co.xml",
+	} {
+		if !slices.Contains(entries, want) {
+			t.Errorf("Share module has no entry %s: %v", want, entries)
+		}
+	}
+	if slices.Contains(entries, "alfresco/module/acme/module.properties") {
+		t.Errorf("Share module has a repository module.properties: %v", entries)
+	}
+
+	context := readTestEntry(t, jarPath, "alfresco/web-extension/acme/module-context.xml")
+	decoder := xml.NewDecoder(strings.NewReader(context))
+	var description strings.Builder
+	inDescription := false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("module-context.xml is not well-formed: %v\n%s", err, context)
+		}
+		switch token := token.(type) {
+		case xml.StartElement:
+			inDescription = token.Name.Local == "description"
+		case xml.EndElement:
+			inDescription = false
+		case xml.CharData:
+			if inDescription {
+				description.Write(token)
+			}
+		}
+	}
+	if want := "alfresco/web-extension/acme/model/acme--v2 & co.xml"; !strings.Contains(description.String(), want) {
+		t.Errorf("module-context.xml description = %q, want it to list %s", description.String(), want)
+	}
+}
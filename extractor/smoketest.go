@@ -0,0 +1,159 @@
+package extractor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// Folder, relative to Company Home, from which the repository bootstraps dynamic models
+const dynamicModelsPath = "Data Dictionary/Models"
+
+// Client of the Alfresco REST API used by -smoke-test
+type smokeTestClient struct {
+	baseURL  string
+	username string
+	password string
+	http     *http.Client
+}
+
+// Node created by the smoke test
+type deployedModel struct {
+	Entry  string
+	NodeID string
+}
+
+// Function to deploy every model as an active dynamic model, which makes the
+// repository bootstrap it, and remove the models again. It returns an error
+// naming the first model the repository refused to load.
+func runSmokeTest(logs logger, alfrescoURL string, files []extractedFile) error {
+	client := &smokeTestClient{
+		baseURL:  strings.TrimSuffix(alfrescoURL, "/"),
+		username: os.Getenv("ALFRESCO_USER"),
+		password: os.Getenv("ALFRESCO_PASSWORD"),
+		http:     &http.Client{Timeout: 60 * time.Second},
+	}
+	if client.username == "" || client.password == "" {
+		return fmt.Errorf("set ALFRESCO_USER and ALFRESCO_PASSWORD with the credentials of an administrator")
+	}
+
+	// Models may import each other, so they are deployed in order and removed in reverse order
+	var deployed []deployedModel
+	defer func() {
+		for i := len(deployed) - 1; i >= 0; i-- {
+			if err := client.deleteNode(deployed[i].NodeID); err != nil {
+				logs.warnf("Could not remove smoke test model %s: %v", deployed[i].Entry, err)
+			}
+		}
+	}()
+
+	for _, file := range files {
+		content, err := readExtractedFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %v", file.Entry, err)
+		}
+		nodeID, err := client.uploadModel(fmt.Sprintf("smoke-test-%d-%s", time.Now().UnixNano(), path.Base(file.Target)), content)
+		if err != nil {
+			return fmt.Errorf("%s was not bootstrapped: %v", file.Entry, err)
+		}
+		deployed = append(deployed, deployedModel{Entry: file.Entry, NodeID: nodeID})
+		logs.resultf("Smoke test: %s bootstrapped\n", file.Entry)
+	}
+	return nil
+}
+
+// Function to upload a model to the dynamic models folder as an active model
+func (c *smokeTestClient) uploadModel(name string, content []byte) (string, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	fields := [][2]string{
+		{"name", name},
+		{"nodeType", "cm:dictionaryModel"},
+		{"relativePath", dynamicModelsPath},
+		{"cm:modelActive", "true"},
+	}
+	for _, field := range fields {
+		if err := writer.WriteField(field[0], field[1]); err != nil {
+			return "", err
+		}
+	}
+	part, err := writer.CreateFormFile("filedata", name)
+	if err != nil {
+		return "", err
+	}
+	if _, err := part.Write(content); err != nil {
+		return "", err
+	}
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+
+	request, err := http.NewRequest(http.MethodPost, c.nodesURL("-root-/children"), &body)
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Content-Type", writer.FormDataContentType())
+
+	var result struct {
+		Entry struct {
+			ID string `json:"id"`
+		} `json:"entry"`
+	}
+	if err := c.do(request, &result); err != nil {
+		return "", err
+	}
+	return result.Entry.ID, nil
+}
+
+// Function to permanently delete a node, skipping the trashcan
+func (c *smokeTestClient) deleteNode(nodeID string) error {
+	request, err := http.NewRequest(http.MethodDelete, c.nodesURL(nodeID)+"?permanent=true", nil)
+	if err != nil {
+		return err
+	}
+	return c.do(request, nil)
+}
+
+// Helper function to build a URL of the public nodes API
+func (c *smokeTestClient) nodesURL(node string) string {
+	return fmt.Sprintf("%s/alfresco/api/-default-/public/alfresco/versions/1/nodes/%s", c.baseURL, node)
+}
+
+// Helper function to send an authenticated request, decoding the JSON response
+// into result and turning error responses into errors
+func (c *smokeTestClient) do(request *http.Request, result any) error {
+	request.SetBasicAuth(c.username, c.password)
+	request.Header.Set("Accept", "application/json")
+	response, err := c.http.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+	if response.StatusCode >= 300 {
+		var failure struct {
+			Error struct {
+				BriefSummary string `json:"briefSummary"`
+			} `json:"error"`
+		}
+		if json.Unmarshal(body, &failure) == nil && failure.Error.BriefSummary != "" {
+			return fmt.Errorf("%s: %s", response.Status, failure.Error.BriefSummary)
+		}
+		return fmt.Errorf("%s", response.Status)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(body, result)
+}
@@ -0,0 +1,35 @@
+package extractor
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// Wall-clock duration of each processing phase, reported with -timings
+type phaseTimings struct {
+	phases    []string
+	durations []time.Duration
+	start     time.Time
+}
+
+// Function to start timing the next phase
+func (t *phaseTimings) begin() {
+	t.start = time.Now()
+}
+
+// Function to record the duration of the phase started by the last begin call
+func (t *phaseTimings) end(phase string) {
+	t.phases = append(t.phases, phase)
+	t.durations = append(t.durations, time.Since(t.start))
+}
+
+// Function to print the recorded phases with their durations and total
+func (t *phaseTimings) print(w io.Writer) {
+	var total time.Duration
+	for i, phase := range t.phases {
+		fmt.Fprintf(w, "%-12s %v\n", phase, t.durations[i])
+		total += t.durations[i]
+	}
+	fmt.Fprintf(w, "%-12s %v\n", "total", total)
+}
@@ -0,0 +1,36 @@
+package extractor
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Pattern of a namespace prefix, an XML name without colons
+var namespacePrefixRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// Function to check that a parsed model has the structure Alfresco requires to
+// bootstrap it: a name and a <namespaces> block where every namespace has a
+// URI and a valid, unique prefix
+func validateModel(model *Model) error {
+	if model.Name == "" {
+		return fmt.Errorf("model has no name attribute")
+	}
+	if len(model.Namespaces) == 0 {
+		return fmt.Errorf("model declares no namespaces")
+	}
+	prefixes := make(map[string]bool, len(model.Namespaces))
+	for _, namespace := range model.Namespaces {
+		if namespace.URI == "" || strings.ContainsAny(namespace.URI, " \t\r\n") {
+			return fmt.Errorf("namespace %q has an invalid uri %q", namespace.Prefix, namespace.URI)
+		}
+		if !namespacePrefixRegex.MatchString(namespace.Prefix) {
+			return fmt.Errorf("namespace %s has an invalid prefix %q", namespace.URI, namespace.Prefix)
+		}
+		if prefixes[namespace.Prefix] {
+			return fmt.Errorf("namespace prefix %s is declared more than once", namespace.Prefix)
+		}
+		prefixes[namespace.Prefix] = true
+	}
+	return nil
+}
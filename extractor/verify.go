@@ -0,0 +1,121 @@
+package extractor
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Function to check, once the archive is written, that every path referenced by a
+// <value> element of module-context.xml uses forward slashes, is relative and
+// points at an entry that exists in the archive, a resource bundle name pointing
+// at its .properties file. Classpath entries are looked up under classpathRoot,
+// e.g. config/ for an AMP, and contextPath is the classpath entry of module-context.xml.
+func verifyContextPaths(jarPath, classpathRoot, contextPath string) error {
+	reader, err := zip.OpenReader(jarPath)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	entries := make(map[string]bool, len(reader.File))
+	var context *zip.File
+	for _, file := range reader.File {
+		name, ok := strings.CutPrefix(file.Name, classpathRoot)
+		if !ok {
+			continue
+		}
+		entries[name] = true
+		if name == contextPath {
+			context = file
+		}
+	}
+	if context == nil {
+		return fmt.Errorf("%s not found", contextPath)
+	}
+
+	rc, err := context.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	content, err := io.ReadAll(rc)
+	if err != nil {
+		return err
+	}
+
+	values, err := contextValues(content)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %v", contextPath, err)
+	}
+	for _, value := range values {
+		// Only values that look like paths are checked
+		if !strings.ContainsAny(value, "/\\") {
+			continue
+		}
+		switch {
+		case strings.Contains(value, "\\"):
+			return fmt.Errorf("path %s in %s contains a backslash", value, contextPath)
+		case strings.HasPrefix(value, "/"):
+			return fmt.Errorf("path %s in %s is not relative", value, contextPath)
+		case !entries[value] && !entries[value+".properties"]:
+			return fmt.Errorf("path %s in %s does not exist in the archive", value, contextPath)
+		}
+	}
+	return nil
+}
+
+// Function to re-open the written archive, reading every entry to the end so
+// that its checksum is verified, and to check that each expected entry is present
+func verifyArchive(archivePath string, expected []string) error {
+	reader, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	entries := make(map[string]bool, len(reader.File))
+	for _, file := range reader.File {
+		rc, err := file.Open()
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %v", file.Name, err)
+		}
+		_, err = io.Copy(io.Discard, rc)
+		rc.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %v", file.Name, err)
+		}
+		entries[file.Name] = true
+	}
+	for _, name := range expected {
+		if !entries[name] {
+			return fmt.Errorf("%s is missing", name)
+		}
+	}
+	return nil
+}
+
+// Helper function to collect the text of every <value> element of a Spring context
+func contextValues(content []byte) ([]string, error) {
+	var values []string
+	decoder := newModelDecoder(bytes.NewReader(content))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return values, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if start, ok := token.(xml.StartElement); ok && start.Name.Local == "value" {
+			var value string
+			if err := decoder.DecodeElement(&value, &start); err != nil {
+				return nil, err
+			}
+			values = append(values, strings.TrimSpace(value))
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package extractor
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+)
+
+const xincludeNamespace = "http://www.w3.org/2001/XInclude"
+
+// Maximum nesting of included documents, guarding against include cycles
+const maxIncludeDepth = 8
+
+// Helper function to check whether a document uses XInclude
+func usesXInclude(content []byte) bool {
+	return bytes.Contains(content, []byte(xincludeNamespace))
+}
+
+// Function to build a reader for archive entries, used to resolve XInclude references
+func archiveEntryReader(reader *zip.Reader) func(name string) ([]byte, error) {
+	entries := make(map[string]*zip.File, len(reader.File))
+	for _, file := range reader.File {
+		entries[file.Name] = file
+	}
+	return func(name string) ([]byte, error) {
+		file, ok := entries[name]
+		if !ok {
+			return nil, fmt.Errorf("entry %s not found in archive", name)
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		return io.ReadAll(rc)
+	}
+}
+
+// Function to replace every xi:include element of a document with the content
+// of the referenced archive entry. The href is resolved relative to base, the
+// entry name of the including document.
+func resolveXIncludes(content []byte, base string, readEntry func(name string) ([]byte, error), depth int) ([]byte, error) {
+	if depth > maxIncludeDepth {
+		return nil, fmt.Errorf("XInclude nesting deeper than %d levels in %s", maxIncludeDepth, base)
+	}
+
+	var resolved bytes.Buffer
+	decoder := newModelDecoder(bytes.NewReader(content))
+	copied := int64(0)
+	for {
+		offset := decoder.InputOffset()
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Space != xincludeNamespace || start.Name.Local != "include" {
+			continue
+		}
+
+		// Skip the whole include element, including any fallback
+		if err := decoder.Skip(); err != nil {
+			return nil, err
+		}
+
+		var href, parse string
+		for _, attr := range start.Attr {
+			switch attr.Name.Local {
+			case "href":
+				href = attr.Value
+			case "parse":
+				parse = attr.Value
+			}
+		}
+		if href == "" {
+			return nil, fmt.Errorf("xi:include without href in %s", base)
+		}
+		target := path.Join(path.Dir(base), href)
+		included, err := readEntry(target)
+		if err != nil {
+			return nil, err
+		}
+
+		// Text includes are inserted escaped, XML includes are resolved recursively
+		if parse == "text" {
+			var escaped bytes.Buffer
+			if err := xml.EscapeText(&escaped, included); err != nil {
+				return nil, err
+			}
+			included = escaped.Bytes()
+		} else {
+			included, err = resolveXIncludes(included, target, readEntry, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			included = stripXMLDeclaration(included)
+		}
+
+		resolved.Write(content[copied:offset])
+		resolved.Write(included)
+		copied = decoder.InputOffset()
+	}
+	resolved.Write(content[copied:])
+	return resolved.Bytes(), nil
+}
+
+// Helper function to remove the BOM and XML declaration from an included document
+func stripXMLDeclaration(content []byte) []byte {
+	content = bytes.TrimPrefix(content, []byte(utf8BOM))
+	trimmed := bytes.TrimLeft(content, " \t\r\n")
+	if bytes.HasPrefix(trimmed, []byte("<?xml")) {
+		if end := bytes.Index(trimmed, []byte("?>")); end >= 0 {
+			return trimmed[end+2:]
+		}
+	}
+	return content
+}
@@ -0,0 +1,47 @@
+package extractor
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+)
+
+// Function to create the decoder used for model content coming from untrusted
+// archives. encoding/xml never fetches external DTDs or entities; Strict mode
+// with no custom Entity map turns any entity other than the predefined XML
+// ones into a parse error instead of resolving it.
+func newModelDecoder(r io.Reader) *xml.Decoder {
+	decoder := xml.NewDecoder(r)
+	decoder.Strict = true
+	decoder.Entity = nil
+	return decoder
+}
+
+// Error returned for documents declaring entities in their DOCTYPE
+var errEntityDeclaration = errors.New("DOCTYPE with entity declarations is not allowed")
+
+// Function to reject documents declaring entities in their DOCTYPE. Such
+// declarations are never expanded by encoding/xml, but they have no place in
+// a content model and are the vehicle of XXE and entity-expansion attacks.
+func checkDoctype(content []byte) error {
+	decoder := newModelDecoder(bytes.NewReader(content))
+	for {
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch t := token.(type) {
+		case xml.Directive:
+			if bytes.HasPrefix(t, []byte("DOCTYPE")) && bytes.Contains(t, []byte("<!ENTITY")) {
+				return errEntityDeclaration
+			}
+		case xml.StartElement:
+			// The DOCTYPE can only appear before the root element
+			return nil
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package extractor
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// Entity-expansion ("billion laughs") document shaped like a content model
+const billionLaughsModel = `<?xml version="1.0"?>
+<!DOCTYPE model [
+  <!ENTITY lol "lol">
+  <!ENTITY lol1 "&lol;&lol;&lol;&lol;&lol;&lol;&lol;&lol;&lol;&lol;">
+  <!ENTITY lol2 "&lol1;&lol1;&lol1;&lol1;&lol1;&lol1;&lol1;&lol1;&lol1;&lol1;">
+  <!ENTITY lol3 "&lol2;&lol2;&lol2;&lol2;&lol2;&lol2;&lol2;&lol2;&lol2;&lol2;">
+]>
+<model name="acme:contentModel" xmlns="http://www.alfresco.org/model/dictionary/1.0">
+  <description>&lol3;</description>
+</model>
+`
+
+func TestParseModelContentRejectsEntityDeclarations(t *testing.T) {
+	if _, err := parseModelContent([]byte(billionLaughsModel)); !errors.Is(err, errEntityDeclaration) {
+		t.Errorf("parseModelContent(billion laughs) error = %v, want %v", err, errEntityDeclaration)
+	}
+	if err := checkDoctype([]byte(billionLaughsModel)); !errors.Is(err, errEntityDeclaration) {
+		t.Errorf("checkDoctype(billion laughs) error = %v, want %v", err, errEntityDeclaration)
+	}
+}
+
+func TestParseModelContentRejectsUndeclaredEntities(t *testing.T) {
+	content := `<model name="acme:contentModel" xmlns="http://www.alfresco.org/model/dictionary/1.0">
+  <description>&lol;</description>
+</model>`
+	if _, err := parseModelContent([]byte(content)); err == nil {
+		t.Error("parseModelContent resolved an undeclared entity, want an error")
+	}
+}
+
+func TestParseModelContent(t *testing.T) {
+	model, err := parseModelContent([]byte(testModel))
+	if err != nil {
+		t.Fatalf("parseModelContent failed: %v", err)
+	}
+	if model.Name != "acme:contentModel" {
+		t.Errorf("model name = %q, want acme:contentModel", model.Name)
+	}
+}
+
+func TestExcludeEntityDeclarations(t *testing.T) {
+	input := writeTestArchive(t, "acme-1.0.jar",
+		testEntry{"lol-model.xml", billionLaughsModel},
+		testEntry{"model.xml", testModel},
+	)
+	output := filepath.Join(t.TempDir(), "models.jar")
+	out, result, err := runExtract(t, Options{Inputs: []string{input}, Output: output})
+	if err != nil {
+		t.Fatalf("Extract failed: %v\n%s", err, out)
+	}
+	// The excluded model counts as skipped
+	if result.Skipped != 1 {
+		t.Errorf("Extract skipped %d archives or models, want 1", result.Skipped)
+	}
+	entries := testArchiveEntries(t, output)
+	if !slices.Contains(entries, testModelPath) {
+		t.Errorf("entries = %v, want %s", entries, testModelPath)
+	}
+	for _, entry := range entries {
+		if strings.HasSuffix(entry, "/lol-model.xml") {
+			t.Errorf("entry %s is packaged, want lol-model.xml excluded", entry)
+		}
+	}
+}
@@ -1,3 +1,5 @@
 module alfresco-model-extractor
 
 go 1.22.5
+
+require golang.org/x/text v0.21.0
@@ -1,366 +1,218 @@
 package main
 
 import (
-	"archive/zip"
-	"bufio"
-	"bytes"
-	"encoding/xml"
 	"flag"
 	"fmt"
-	"io"
 	"log"
 	"os"
-	"path/filepath"
-	"regexp"
-	"sort"
-	"strconv"
 	"strings"
-	"text/template"
-	"time"
-)
 
-// Simple XML structure to check for model declaration
-type Model struct {
-	XMLName xml.Name `xml:"model"`
-	Name    string   `xml:"name,attr"`
-}
+	"alfresco-model-extractor/extractor"
+)
 
-// Templates for generated files
-const modulePropertiesTmpl = `module.id={{.Name}}
-module.title={{.Name}}
-module.description={{.Name}}
-module.version={{.Version}}
-`
+// Version of the extractor, set at build time with -ldflags "-X main.version=..."
+var version = "dev"
 
-const moduleContextXmlTmpl = `<?xml version='1.0' encoding='UTF-8'?>
-<beans xmlns="http://www.springframework.org/schema/beans"
-       xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance"
-       xsi:schemaLocation="http://www.springframework.org/schema/beans
-          http://www.springframework.org/schema/beans/spring-beans-3.0.xsd">
-    <bean id="{{.Name}}" parent="dictionaryModelBootstrap" depends-on="dictionaryBootstrap">
-        <property name="models">
-            <list>
-                {{- range .ModelPaths}}
-                <value>{{.}}</value>
-                {{- end}}
-            </list>
-        </property>
-    </bean>
-</beans>`
+// Repeatable command line flag collecting every value it is given
+type stringList []string
 
-type ModuleData struct {
-	Name       string
-	Version    string
-	ModelPaths []string
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
 }
 
-// Function to extract and parse module.properties from ZIP
-func getModuleVersion(zipReader *zip.ReadCloser, moduleName string) (string, error) {
-	propertiesPath := fmt.Sprintf("alfresco/module/%s/module.properties", moduleName)
-	for _, file := range zipReader.File {
-		if file.Name == propertiesPath {
-			rc, err := file.Open()
-			if err != nil {
-				return "", err
-			}
-			defer rc.Close()
-
-			scanner := bufio.NewScanner(rc)
-			for scanner.Scan() {
-				line := scanner.Text()
-				if strings.HasPrefix(line, "module.version=") {
-					return strings.TrimPrefix(line, "module.version="), nil
-				}
-			}
-			return "", scanner.Err()
-		}
-	}
-	return "1.0.0", nil // Default version if not found
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
 }
 
-// Function to increment version
-func incrementVersion(version string) string {
-	parts := strings.Split(version, ".")
-	if len(parts) < 3 {
-		// If version is incomplete, pad with zeros
-		for len(parts) < 3 {
-			parts = append(parts, "0")
-		}
-	}
-
-	// Try to increment the last number
-	if lastNum, err := strconv.Atoi(parts[len(parts)-1]); err == nil {
-		parts[len(parts)-1] = strconv.Itoa(lastNum + 1)
-	} else {
-		// If parsing fails, append .1
-		parts = append(parts, "1")
-	}
-
-	return strings.Join(parts, ".")
-}
+// Exit status of a build that completed but skipped archives or models it could not process
+const exitPartialFailure = 2
 
+// Function to parse the command line and run the extraction. The exit status is
+// 0 when everything was processed, exitPartialFailure when something was skipped
+// and 1 when the build failed.
 func main() {
 	// Parse command line arguments
-	zipFile := flag.String("zip", "", "Path to ZIP file to process")
-	outputJar := flag.String("output", "models.jar", "Output JAR file name")
+	defaults := extractor.DefaultOptions()
+	toolVersion := flag.Bool("tool-version", false, "Print the version of the extractor and exit")
+	recursive := flag.Bool("recursive", false, "Walk -zip directories and process every .zip, .amp and .jar archive found")
+	var zipFiles stringList
+	flag.Var(&zipFiles, "zip", "Path to ZIP file to process; can be repeated or given as a comma-separated list")
+	outputJar := flag.String("output", "", "Output JAR file name (default \"models.jar\", or derived from the module with -output-dir)")
+	builtBy := flag.String("built-by", "", "Built-By of the manifest (default $USER)")
+	verify := flag.Bool("verify", false, "Re-open the output and check that every entry is present and readable")
+	checksums := flag.String("checksums", "", "Comma-separated digests to write next to the output as <output>.<algorithm>: sha256, md5")
+	requireProperties := flag.Bool("require-properties", false, "Fail when no input provides a module.properties with a module.version, instead of assuming "+extractor.DefaultModuleVersion)
+	outputDir := flag.String("output-dir", "", "Directory of the output when -output is not set, named <module>-<version>.jar")
+	outputFormat := flag.String("format", defaults.Format, "Package format of the output: jar (repository JAR), amp (Alfresco Module Package) or targz (tarball of the JAR tree)")
+	tier := flag.String("tier", defaults.Tier, "Tier the module is built for: repo (alfresco/module layout) or share (alfresco/web-extension layout)")
+	templatesDir := flag.String("templates", "", "Directory with template overrides (module.properties.tmpl, module-context.xml.tmpl, manifest.tmpl)")
+	contextTemplate := flag.String("context-template", "", "Template file used to render module-context.xml instead of the built-in one")
+	noVersionIncrement := flag.Bool("no-version-increment", false, "Use the version found in module.properties as is, without incrementing it")
+	versionFlag := flag.String("version", "", "Version of the output module, used verbatim instead of incrementing the version found in module.properties")
+	bump := flag.String("bump", defaults.Bump, "Version component to increment: major, minor or patch")
+	keepSnapshot := flag.Bool("keep-snapshot", false, "Keep a -SNAPSHOT pre-release suffix when incrementing the version")
+	buildNumberFrom := flag.String("build-number-from", "", "Environment variable (e.g. BUILD_NUMBER) whose value is appended to the version")
+	buildNumberStyle := flag.String("build-number-style", defaults.BuildNumberStyle, "How the build number is appended: segment (1.2.3.45) or metadata (1.2.3+45)")
+	withWorkflows := flag.Bool("workflows", false, "Also package BPMN workflow definitions (*.bpmn20.xml)")
+	flag.BoolVar(withWorkflows, "with-workflows", false, "Same as -workflows, named after -with-messages")
+	withMessages := flag.Bool("with-messages", false, "Also package the message bundles (*.properties in a messages folder) and register them in module-context.xml")
+	diagramFile := flag.String("diagram", "", "Write a PlantUML class diagram of the models to this file")
+	layerFile := flag.String("layer", "", "Also write the module as a tar layer for a container image to this file")
+	imagePath := flag.String("image-path", defaults.ImagePath, "Alfresco classpath directory inside the container image used by -layer")
+	closureRoot := flag.String("closure", "", "Package only this model (e.g. acme:contentModel) and the models it imports, transitively")
+	progress := flag.Bool("progress", false, "Print a running count of the scanned entries and models found to stderr")
+	normalize := flag.Bool("normalize", false, "Re-indent the models with two spaces and a canonical XML declaration")
+	mergeFlag := flag.String("merge", "", "Merge every model into a single model file with this prefix:name, e.g. acme:combinedModel")
+	mergeModelsFlag := flag.Bool("merge-models", false, "Merge models sharing the same namespace into a single model file")
+	reportFile := flag.String("report", "", "Write a JSON report describing each packaged model to this file")
+	emitGenerated := flag.String("emit-generated", "", "Directory where the rendered module.properties, module-context.xml and MANIFEST.MF are also written")
+	sourceDateFlag := flag.String("source-date", "", "Timestamp of every archive entry, as RFC3339 or unix epoch, for reproducible builds (defaults to SOURCE_DATE_EPOCH)")
+	sourceIndexFlag := flag.Bool("source-index", false, "Write META-INF/model-sources.properties mapping each packaged file to its source archive entry")
+	var excludeFiles stringList
+	flag.Var(&excludeFiles, "exclude-file", "Path of an archive entry never to package as a model, e.g. alfresco/extension/foo-model.xml; can be repeated")
+	var matchPatterns, skipPatterns stringList
+	flag.Var(&matchPatterns, "match", "Only consider XML entries whose base name matches this glob pattern, e.g. '*-model.xml'; can be repeated")
+	flag.Var(&skipPatterns, "skip", "Ignore XML entries whose base name matches this glob pattern, e.g. 'test-*.xml'; can be repeated")
+	var includePrefixes, excludePrefixes stringList
+	flag.Var(&includePrefixes, "include-prefix", "Only package models declaring this namespace prefix; can be repeated")
+	flag.Var(&excludePrefixes, "exclude-prefix", "Leave out models declaring this namespace prefix; can be repeated")
+	var forbiddenNamespaces stringList
+	flag.Var(&forbiddenNamespaces, "forbid-namespace", "Namespace URI no model may declare; can be repeated")
+	lockFile := flag.String("lock", "", "Lock file recording the content hash of each model; created on the first run and checked afterwards")
+	updateLock := flag.Bool("update-lock", false, "Rewrite the -lock file with the current model hashes instead of failing on changes")
+	lint := flag.Bool("lint", false, "Report model lint findings, such as property defaults not matching their data type")
+	listNamespaces := flag.Bool("list-namespaces", false, "Print the namespaces declared by the models instead of building the JAR")
+	allowPrefixConflicts := flag.Bool("allow-prefix-conflicts", false, "Only warn, instead of failing, when models declare the same namespace prefix with different URIs")
+	listFormat := flag.String("list-format", defaults.ListFormat, "Output format of -list-namespaces: text or json")
+	smokeTestURL := flag.String("smoke-test", "", "Alfresco URL (e.g. http://localhost:8080) where the models are deployed and removed again to check they bootstrap")
+	showTimings := flag.Bool("timings", false, "Print the duration of each processing phase to stderr")
+	allowEmpty := flag.Bool("allow-empty", false, "Create a module without models instead of failing when the archive contains no models")
+	stripBOM := flag.Bool("strip-bom", false, "Remove a leading UTF-8 BOM from the models written into the JAR")
+	validate := flag.Bool("validate", false, "Exclude models that cannot be parsed or lack a <namespaces> block with a valid uri and prefix")
+	strict := flag.Bool("strict", false, "Fail the run when a model does not pass -validate (implies -validate)")
+	nestedDepth := flag.Int("nested-depth", defaults.NestedDepth, "Levels of ZIP, AMP and JAR files nested in the inputs that are scanned for models (0 disables it)")
+	failFast := flag.Bool("fail-fast", false, "Abort on the first archive entry that cannot be scanned instead of reporting all of them at the end")
+	idSource := flag.String("id-source", defaults.IDSource, "What populates module.id: filename (the module name), model (the first model name) or flag (the -id value)")
+	moduleIDFlag := flag.String("id", "", "Module id used when -id-source is flag")
+	title := flag.String("title", "", "Value of module.title in module.properties, shown in the admin console (default: the module name)")
+	description := flag.String("description", "", "Value of module.description in module.properties (default: the module name)")
+	var propFlags stringList
+	flag.Var(&propFlags, "prop", "Additional key=value entry of module.properties (repeatable)")
+	var manifestEntries stringList
+	flag.Var(&manifestEntries, "manifest-entry", "Additional Name=value header of MANIFEST.MF, e.g. Git-Commit=abc123 (repeatable)")
+	installState := flag.String("install-state", "", "Value of module.installState in module.properties: INSTALLED, DISABLED or UNINSTALLED")
+	aliasesFlag := flag.String("aliases", "", "Comma-separated former module ids written as module.aliases in module.properties")
+	nameFlag := flag.String("name", "", "Module name used in the module paths, templates and manifest instead of the one derived from the first ZIP filename")
+	renameFlag := flag.String("rename", "", "Rename the module with a regular expression replacement, as pattern=replacement (e.g. ^old-prefix-=new-prefix-)")
+	normalizeName := flag.Bool("normalize-module-name", false, "Lowercase the module name and replace spaces and invalid characters with hyphens")
+	entryNameEncodingFlag := flag.String("entry-name-encoding", defaults.EntryNameEncoding, "Encoding of entry names not flagged as UTF-8 in the ZIP, e.g. IBM437 or Shift_JIS")
+	trimPrefixFlag := flag.String("trim-prefix", "", "Prefix stripped from archive entry names before processing, e.g. target/classes/")
+	modelDirFlag := flag.String("model-dir", defaults.ModelDir, "Directory inside the module where models are placed, e.g. model/custom")
+	groupNamespaces := flag.Bool("group-by-namespace", false, "Place each model in a subdirectory of the model directory named after its namespace prefix")
+	conventionPaths := flag.Bool("convention-paths", false, "Keep each model path relative to its classpath convention (alfresco/extension, alfresco/module/*/model) instead of flattening it")
+	compression := flag.String("compression", "", "Compression of the JAR entries: a flate level from 1 (fastest) to 9 (smallest), or 0/store for no compression (default: the standard level)")
+	dryRun := flag.Bool("dry-run", false, "Print the entries and version of the module that would be created without writing any output")
+	onCollision := flag.String("on-collision", defaults.OnCollision, "What to do when distinct models share an output path: rename (the later ones with a numeric suffix) or fail")
+	preservePaths := flag.Bool("preserve-paths", false, "Keep each model path from the source archive under the model directory instead of flattening it")
+	quiet := flag.Bool("quiet", false, "Only print errors, suppressing the build summary and warnings; same as -log-level error")
+	logLevelFlag := flag.String("log-level", defaults.LogLevel, "Verbosity of the messages: debug, info, warn or error")
 	flag.Parse()
 
-	if *zipFile == "" {
-		log.Fatal("Please provide a ZIP file path using -zip flag")
-	}
-
-	// Get module name from ZIP filename, removing version information
-	moduleName := cleanModuleName(*zipFile)
-
-	// Open the ZIP file
-	reader, err := zip.OpenReader(*zipFile)
-	if err != nil {
-		log.Fatalf("Failed to open ZIP file: %v", err)
-	}
-	defer reader.Close()
-
-	// Get current version from module.properties
-	currentVersion, err := getModuleVersion(reader, moduleName)
-	if err != nil {
-		log.Printf("Warning: Could not read current version: %v", err)
-		currentVersion = "1.0.0"
-	}
-
-	// Increment the version
-	newVersion := incrementVersion(currentVersion)
-
-	// Create temporary directory for XML files
-	tempDir, err := os.MkdirTemp("", "alfresco-models")
-	if err != nil {
-		log.Fatalf("Failed to create temp directory: %v", err)
+	if *toolVersion {
+		fmt.Printf("alfresco-model-extractor %s\n", version)
+		return
 	}
-	defer os.RemoveAll(tempDir)
 
-	// Process ZIP contents
-	modelFiles := make([]string, 0)
-	for _, file := range reader.File {
-		if strings.HasSuffix(strings.ToLower(file.Name), ".xml") {
-			if isAlfrescoModel(file) {
-				// Copy file to temp directory
-				destPath := filepath.Join(tempDir, filepath.Base(file.Name))
-				if err := extractFile(file, destPath); err != nil {
-					log.Printf("Failed to extract %s: %v", file.Name, err)
-					continue
-				}
-				modelFiles = append(modelFiles, destPath)
-			}
+	// An explicit version must not be empty
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "version" && strings.TrimSpace(*versionFlag) == "" {
+			log.Fatal("-version must not be empty")
 		}
-	}
-
-	if len(modelFiles) == 0 {
-		log.Fatal("No Alfresco content model XML files found")
-	}
-
-	// Create JAR file with module structure and new version
-	if err := createModuleJar(*outputJar, modelFiles, moduleName, newVersion); err != nil {
-		log.Fatalf("Failed to create JAR file: %v", err)
-	}
-
-	fmt.Printf("Successfully created JAR file %s with %d model files (version %s)\n", 
-		*outputJar, len(modelFiles), newVersion)
-}
-
-func cleanModuleName(filename string) string {
-	// Remove file extension
-	name := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
-
-	// Regular expression to match version patterns:
-	// - Matches patterns like "-1.0.0", "-1.0", "-v1.0.0", "_1.0.0", "_v1.0.0"
-	// - Handles both hyphen and underscore separators
-	// - Handles optional 'v' prefix before version number
-	versionRegex := regexp.MustCompile(`[-_]v?\d+(\.\d+)*(-SNAPSHOT)?$`)
-
-	// Remove version information
-	cleanName := versionRegex.ReplaceAllString(name, "")
-
-	return cleanName
-}
-
-func isAlfrescoModel(file *zip.File) bool {
-	rc, err := file.Open()
-	if err != nil {
-		return false
-	}
-	defer rc.Close()
-
-	// Read the first few KB to check for model declaration
-	buffer := make([]byte, 4096)
-	n, err := rc.Read(buffer)
-	if err != nil && err != io.EOF {
-		return false
-	}
-
-	// Check if it contains model declaration
-	content := string(buffer[:n])
-	return strings.Contains(content, "<model") && strings.Contains(content, "name=")
-}
-
-func extractFile(file *zip.File, destPath string) error {
-	rc, err := file.Open()
+	})
+
+	result, err := extractor.Extract(extractor.Options{
+		Recursive:            *recursive,
+		Inputs:               zipFiles,
+		Output:               *outputJar,
+		OutputDir:            *outputDir,
+		RequireProperties:    *requireProperties,
+		Checksums:            *checksums,
+		Verify:               *verify,
+		BuiltBy:              *builtBy,
+		Format:               *outputFormat,
+		Tier:                 *tier,
+		TemplatesDir:         *templatesDir,
+		ContextTemplate:      *contextTemplate,
+		NoVersionIncrement:   *noVersionIncrement,
+		Version:              *versionFlag,
+		Bump:                 *bump,
+		KeepSnapshot:         *keepSnapshot,
+		BuildNumberFrom:      *buildNumberFrom,
+		BuildNumberStyle:     *buildNumberStyle,
+		Workflows:            *withWorkflows,
+		Messages:             *withMessages,
+		Diagram:              *diagramFile,
+		Layer:                *layerFile,
+		ImagePath:            *imagePath,
+		Closure:              *closureRoot,
+		MergeModels:          *mergeModelsFlag,
+		Merge:                *mergeFlag,
+		Normalize:            *normalize,
+		Progress:             *progress,
+		Report:               *reportFile,
+		EmitGenerated:        *emitGenerated,
+		SourceDate:           *sourceDateFlag,
+		SourceIndex:          *sourceIndexFlag,
+		ForbiddenNamespaces:  forbiddenNamespaces,
+		IncludePrefixes:      includePrefixes,
+		MatchPatterns:        matchPatterns,
+		SkipPatterns:         skipPatterns,
+		ExcludeFiles:         excludeFiles,
+		ExcludePrefixes:      excludePrefixes,
+		Lock:                 *lockFile,
+		UpdateLock:           *updateLock,
+		Lint:                 *lint,
+		ListNamespaces:       *listNamespaces,
+		AllowPrefixConflicts: *allowPrefixConflicts,
+		ListFormat:           *listFormat,
+		SmokeTestURL:         *smokeTestURL,
+		Timings:              *showTimings,
+		AllowEmpty:           *allowEmpty,
+		StripBOM:             *stripBOM,
+		Validate:             *validate,
+		Strict:               *strict,
+		NestedDepth:          *nestedDepth,
+		FailFast:             *failFast,
+		IDSource:             *idSource,
+		ID:                   *moduleIDFlag,
+		Title:                *title,
+		Description:          *description,
+		Properties:           propFlags,
+		ManifestEntries:      manifestEntries,
+		InstallState:         *installState,
+		Aliases:              *aliasesFlag,
+		Name:                 *nameFlag,
+		Rename:               *renameFlag,
+		NormalizeName:        *normalizeName,
+		EntryNameEncoding:    *entryNameEncodingFlag,
+		TrimPrefix:           *trimPrefixFlag,
+		ModelDir:             *modelDirFlag,
+		GroupByNamespace:     *groupNamespaces,
+		ConventionPaths:      *conventionPaths,
+		Compression:          *compression,
+		DryRun:               *dryRun,
+		OnCollision:          *onCollision,
+		PreservePaths:        *preservePaths,
+		LogLevel:             *logLevelFlag,
+		Quiet:                *quiet,
+		ToolVersion:          version,
+	})
 	if err != nil {
-		return err
+		log.Fatal(err)
 	}
-	defer rc.Close()
-
-	dest, err := os.Create(destPath)
-	if err != nil {
-		return err
+	// Extract already warned about what it skipped
+	if result.Skipped > 0 {
+		os.Exit(exitPartialFailure)
 	}
-	defer dest.Close()
-
-	_, err = io.Copy(dest, rc)
-	return err
-}
-
-// Helper function to create a directory entry in the ZIP
-func createDirInZip(zipWriter *zip.Writer, name string) error {
-	if !strings.HasSuffix(name, "/") {
-		name = name + "/"
-	}
-	header := &zip.FileHeader{
-		Name:     name,
-		Method:   zip.Store, // Directories should use STORE method
-		Modified: time.Now(),
-	}
-	header.SetMode(0755 | os.ModeDir)
-	_, err := zipWriter.CreateHeader(header)
-	return err
-}
-
-// Helper function to create a file in the ZIP with current timestamp
-func createFileInZip(zipWriter *zip.Writer, name string, compress bool) (io.Writer, error) {
-	header := &zip.FileHeader{
-		Name:     name,
-		Modified: time.Now(),
-	}
-	if compress {
-		header.Method = zip.Deflate
-	} else {
-		header.Method = zip.Store
-	}
-	header.SetMode(0644)
-	return zipWriter.CreateHeader(header)
-}
-
-func createModuleJar(jarPath string, files []string, moduleName, version string) error {
-	jarFile, err := os.Create(jarPath)
-	if err != nil {
-		return err
-	}
-	defer jarFile.Close()
-
-	zipWriter := zip.NewWriter(jarFile)
-	defer zipWriter.Close()
-
-	// Create all necessary directories first
-	directories := []string{
-		"META-INF/",
-		fmt.Sprintf("alfresco/"),
-		fmt.Sprintf("alfresco/module/"),
-		fmt.Sprintf("alfresco/module/%s/", moduleName),
-		fmt.Sprintf("alfresco/module/%s/model/", moduleName),
-	}
-
-	// Sort directories to ensure parent directories are created first
-	sort.Strings(directories)
-	for _, dir := range directories {
-		if err := createDirInZip(zipWriter, dir); err != nil {
-			return fmt.Errorf("failed to create directory %s: %v", dir, err)
-		}
-	}
-
-	// Create META-INF/MANIFEST.MF
-	manifest := []byte(fmt.Sprintf("Manifest-Version: 1.0\n"+
-		"Created-By: Alfresco Model Extractor\n"+
-		"Built-By: %s\n"+
-		"Build-Jdk: 17.0.5\n"+
-		"Package: org.alfresco.module\n"+
-		"Implementation-Version: %s\n"+
-		"Implementation-Title: %s\n\n",
-		os.Getenv("USER"),
-		version,
-		moduleName))
-
-	manifestWriter, err := createFileInZip(zipWriter, "META-INF/MANIFEST.MF", false)
-	if err != nil {
-		return err
-	}
-	if _, err := manifestWriter.Write(manifest); err != nil {
-		return err
-	}
-
-	// Prepare model paths for module-context.xml
-	var modelPaths []string
-	for _, file := range files {
-		modelPath := fmt.Sprintf("alfresco/module/%s/model/%s", moduleName, filepath.Base(file))
-		// Ensure forward slashes
-		modelPath = strings.ReplaceAll(modelPath, "\\", "/")
-		modelPaths = append(modelPaths, modelPath)
-	}
-
-	// Sort model paths for consistency
-	sort.Strings(modelPaths)
-
-	// Prepare module data for templates with version
-	moduleData := ModuleData{
-		Name:       moduleName,
-		Version:    version,
-		ModelPaths: modelPaths,
-	}
-
-	// Create module.properties
-	propsTemplate := template.Must(template.New("properties").Parse(modulePropertiesTmpl))
-	var propsBuffer bytes.Buffer
-	if err := propsTemplate.Execute(&propsBuffer, moduleData); err != nil {
-		return err
-	}
-	propsWriter, err := createFileInZip(zipWriter, fmt.Sprintf("alfresco/module/%s/module.properties", moduleName), true)
-	if err != nil {
-		return err
-	}
-	if _, err := propsWriter.Write(propsBuffer.Bytes()); err != nil {
-		return err
-	}
-
-	// Create module-context.xml
-	contextTemplate := template.Must(template.New("context").Parse(moduleContextXmlTmpl))
-	var contextBuffer bytes.Buffer
-	if err := contextTemplate.Execute(&contextBuffer, moduleData); err != nil {
-		return err
-	}
-	contextWriter, err := createFileInZip(zipWriter, fmt.Sprintf("alfresco/module/%s/module-context.xml", moduleName), true)
-	if err != nil {
-		return err
-	}
-	if _, err := contextWriter.Write(contextBuffer.Bytes()); err != nil {
-		return err
-	}
-
-	// Add XML files to JAR in the module's model directory
-	for _, file := range files {
-		content, err := os.ReadFile(file)
-		if err != nil {
-			return err
-		}
-
-		fileName := fmt.Sprintf("alfresco/module/%s/model/%s", moduleName, filepath.Base(file))
-		// Ensure forward slashes
-		fileName = strings.ReplaceAll(fileName, "\\", "/")
-
-		writer, err := createFileInZip(zipWriter, fileName, true)
-		if err != nil {
-			return err
-		}
-
-		if _, err := writer.Write(content); err != nil {
-			return err
-		}
-	}
-
-	return nil
 }
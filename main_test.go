@@ -0,0 +1,115 @@
+package main
+
+import (
+	"archive/zip"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// Environment variable making the test binary run the extractor, see runExtractor
+const runMainEnv = "AME_TEST_RUN_MAIN"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(runMainEnv) == "1" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// Smallest content model detected by the extractor
+const testModel = `<?xml version="1.0" encoding="UTF-8"?>
+<model name="acme:contentModel" xmlns="http://www.alfresco.org/model/dictionary/1.0">
+  <namespaces>
+    <namespace uri="http://www.acme.org/model/content/1.0" prefix="acme"/>
+  </namespaces>
+</model>
+`
+
+// Model declaring an entity, which the extractor skips
+const entityModel = `<?xml version="1.0"?>
+<!DOCTYPE model [
+  <!ENTITY lol "lol">
+]>
+<model name="acme:lolModel" xmlns="http://www.alfresco.org/model/dictionary/1.0">
+  <description>&lol;</description>
+</model>
+`
+
+// Helper function to write a ZIP archive with the given entries into a temp dir
+func writeTestArchive(t testing.TB, name string, entries map[string]string) string {
+	t.Helper()
+	archivePath := filepath.Join(t.TempDir(), name)
+	file, err := os.Create(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	zipWriter := zip.NewWriter(file)
+	for entryName, content := range entries {
+		writer, err := zipWriter.Create(entryName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := writer.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zipWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return archivePath
+}
+
+// Helper function to run the extractor with args in a child process, returning
+// its combined output and exit status
+func runExtractor(t testing.TB, args ...string) (string, int) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(os.Environ(), runMainEnv+"=1")
+	cmd.Dir = t.TempDir()
+	output, err := cmd.CombinedOutput()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return string(output), exitErr.ExitCode()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(output), 0
+}
+
+func TestToolVersion(t *testing.T) {
+	out, status := runExtractor(t, "-tool-version")
+	if status != 0 || out != "alfresco-model-extractor dev\n" {
+		t.Errorf("-tool-version printed %q with status %d, want the extractor version", out, status)
+	}
+	// -version only sets the module version, so it needs a value
+	if out, status := runExtractor(t, "-version"); status == 0 {
+		t.Errorf("-version without a value succeeded:\n%s", out)
+	}
+}
+
+func TestExitStatus(t *testing.T) {
+	input := writeTestArchive(t, "acme-1.0.jar", map[string]string{"model.xml": testModel})
+	partial := writeTestArchive(t, "acme-1.0.jar", map[string]string{"model.xml": testModel, "lol-model.xml": entityModel})
+	tests := []struct {
+		name   string
+		args   []string
+		status int
+	}{
+		{"success", []string{"-zip", input, "-output", "models.jar"}, 0},
+		{"failure", []string{"-zip", input, "-output", input}, 1},
+		{"skipped model", []string{"-zip", partial, "-output", "models.jar"}, exitPartialFailure},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if out, status := runExtractor(t, tt.args...); status != tt.status {
+				t.Errorf("extractor exited with status %d, want %d:\n%s", status, tt.status, out)
+			}
+		})
+	}
+}